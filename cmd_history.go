@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/history"
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// 查询测速历史记录的子命令：cfst history [参数]
+func historyCommand(args []string) {
+	var help = `
+CloudflareSpeedTest ` + version + `
+查询测速历史记录 (需先通过 [-history] 参数记录每次测速的结果)
+
+用法：
+    cfst history [参数]
+
+参数：
+    -f cfst_history.db
+        历史记录文件；(默认 cfst_history.db)
+    -runs 10
+        统计最近几次测速；为 0 时统计全部历史记录；(默认 0)
+    -ip 1.1.1.1
+        查询指定 IP；输出该 IP 每次测速的结果及统计；(默认 空，按稳定性输出所有 IP 的统计)
+    -p 10
+        显示结果数量；为 0 时显示全部；(默认 10 个)
+    -h
+        打印帮助说明
+`
+	var path, ip string
+	var runs, num int
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.StringVar(&path, "f", history.DefaultPath, "历史记录文件")
+	fs.IntVar(&runs, "runs", 0, "统计最近几次测速")
+	fs.StringVar(&ip, "ip", "", "查询指定 IP")
+	fs.IntVar(&num, "p", utils.DefaultPrintNum, "显示结果数量")
+	fs.Usage = func() { fmt.Print(help) }
+	_ = fs.Parse(args)
+
+	db := history.Open(path)
+	if ip != "" { // 只读取该 IP 的记录
+		recent, _, err := db.Recent(runs, nil)
+		if err != nil {
+			utils.Red.Println(err)
+			os.Exit(1)
+		}
+		if len(recent) == 0 {
+			fmt.Printf("[信息] 历史记录文件[%s]中没有测速记录。\n", path)
+			return
+		}
+		records, err := db.IPRecords(ip, recent[0])
+		if err != nil {
+			utils.Red.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("[信息] 共统计 %d 次测速的历史记录。\n\n", len(recent))
+		printIPHistory(records, len(recent))
+		return
+	}
+
+	records, err := db.Records()
+	if err != nil {
+		utils.Red.Println(err)
+		os.Exit(1)
+	}
+	records = history.Last(records, runs)
+	total := len(history.Runs(records))
+	if total == 0 {
+		fmt.Printf("[信息] 历史记录文件[%s]中没有测速记录。\n", path)
+		return
+	}
+	fmt.Printf("[信息] 共统计 %d 次测速的历史记录。\n\n", total)
+	printSummaries(history.Summarize(records), num)
+}
+
+// 打印单个 IP 每次测速的结果及统计
+func printIPHistory(records []history.Record, total int) {
+	if len(records) == 0 {
+		fmt.Println("[信息] 历史记录中没有该 IP 的测速结果。")
+		return
+	}
+	headFormat := "%-17s%-5s%-5s%-5s%-6s%-12s%-5s\n"
+	dataFormat := "%-21s%-8s%-8s%-8s%-10s%-16s%-8s\n"
+	utils.Cyan.Printf(headFormat, "测速时间", "已发送", "已接收", "丢包率", "平均延迟", "下载速度(MB/s)", "地区码")
+	for _, r := range records {
+		fmt.Printf(dataFormat, r.Time.Local().Format("2006-01-02 15:04"), strconv.Itoa(r.Sent), strconv.Itoa(r.Received),
+			formatFloat(r.LossRate), formatFloat(r.Delay), formatFloat(r.DownloadSpeed), coloOrNA(r.Colo))
+	}
+	s := history.Summarize(records)[0]
+	s.TotalRuns = total
+	fmt.Printf("\n出现次数：%d/%d, 平均丢包率：%s, 延迟中位数：%s ms, 延迟波动：%s ms, 下载速度中位数：%s MB/s\n",
+		s.Runs, s.TotalRuns, formatFloat(s.LossRate), formatFloat(s.MedianDelay), formatFloat(s.DelayStdDev), formatFloat(s.MedianSpeed))
+}
+
+// 按稳定性打印前 num 个 IP 的统计
+func printSummaries(summaries []history.Summary, num int) {
+	if num <= 0 || num > len(summaries) {
+		num = len(summaries)
+	}
+	headFormat := "%-16s%-6s%-5s%-7s%-6s%-12s%-5s%-8s\n"
+	dataFormat := "%-18s%-10s%-8s%-12s%-10s%-16s%-8s%-16s\n"
+	for i := 0; i < num; i++ {
+		if len(summaries[i].IP) > 15 { // IPv6 地址较长时调整格式
+			headFormat = "%-40s%-6s%-5s%-7s%-6s%-12s%-5s%-8s\n"
+			dataFormat = "%-42s%-10s%-8s%-12s%-10s%-16s%-8s%-16s\n"
+			break
+		}
+	}
+	utils.Cyan.Printf(headFormat, "IP 地址", "出现次数", "丢包率", "延迟中位数", "延迟波动", "速度中位数(MB/s)", "地区码", "最近出现")
+	for _, s := range summaries[:num] {
+		fmt.Printf(dataFormat, s.IP, fmt.Sprintf("%d/%d", s.Runs, s.TotalRuns), formatFloat(s.LossRate), formatFloat(s.MedianDelay),
+			formatFloat(s.DelayStdDev), formatFloat(s.MedianSpeed), coloOrNA(s.Colo), s.LastSeen.Local().Format("01-02 15:04"))
+	}
+}
+
+// 保留两位小数
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// 地区码为空时使用 "N/A" 表示
+func coloOrNA(colo string) string {
+	if colo == "" {
+		return "N/A"
+	}
+	return colo
+}
+
+// 记录本次测速结果到历史记录文件，被中断的测速结果不完整，不记录
+func recordHistory(ctx context.Context, end time.Time, data utils.DownloadSpeedSet) {
+	if historyFile == "" || ctx.Err() != nil || len(data) == 0 {
+		return
+	}
+	if err := history.Open(historyFile).Add(end, data); err != nil {
+		utils.Red.Printf("[错误] %v\n", err)
+	}
+}
+
+// 结合历史记录按稳定性评分对测速结果排序，byDelay 为 true 时按延迟评分（未进行下载测速）
+func rankStable(data utils.DownloadSpeedSet, byDelay bool) utils.DownloadSpeedSet {
+	if len(data) == 0 {
+		return data
+	}
+	ips := make([]string, 0, len(data))
+	for i := range data {
+		ips = append(ips, data[i].IP.String())
+	}
+	runs, past, err := history.Open(historyFile).Recent(stableRuns, ips)
+	if err != nil {
+		utils.Red.Printf("[错误] %v，按本次测速结果排序\n", err)
+		return data
+	}
+	scores := history.Rank(data, runs, past, byDelay)
+	best := scores[0]
+	utils.Cyan.Printf("[信息] 已结合最近 %d 次测速历史按稳定性排序，最优 IP：%s（参与评分 %d 次测速，失败率 %.2f，出现率 %.2f）\n",
+		len(runs), best.IP, best.Samples, best.FailRate, best.Presence)
+	return data
+}
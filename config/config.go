@@ -0,0 +1,185 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	profilesKey = "profiles" // 配置档（按名称区分的多组参数）
+	profileKey  = "profile"  // 默认使用的配置档
+)
+
+// Rule 检查参数值是否有效
+type Rule func(value string) error
+
+// Load 读取配置文件（.toml 为 TOML，其余为 YAML），返回通用参数与所选配置档合并后的参数
+// 配置文件中的键与命令行参数名称一致，profiles 下为各配置档，profile 为默认使用的配置档
+// profile 为空时使用配置文件中的默认配置档（未指定则只使用通用参数）
+func Load(path, profile string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件[%s]失败：%w", path, err)
+	}
+	values := make(map[string]any)
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(data, &values)
+	} else {
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件[%s]失败：%w", path, err)
+	}
+
+	profiles, _ := values[profilesKey].(map[string]any)
+	if _, ok := values[profilesKey]; ok && profiles == nil {
+		return nil, fmt.Errorf("配置文件[%s]中 %s 的格式有误", path, profilesKey)
+	}
+	if profile == "" {
+		profile, _ = values[profileKey].(string)
+	}
+	delete(values, profilesKey)
+	delete(values, profileKey)
+	if profile == "" {
+		return values, nil
+	}
+
+	selected, ok := profiles[profile].(map[string]any)
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("配置文件[%s]中不存在配置档[%s]（可用：%s）", path, profile, strings.Join(names, ", "))
+	}
+	for k, v := range selected { // 配置档中的参数覆盖通用参数
+		values[k] = v
+	}
+	return values, nil
+}
+
+// Apply 将参数写入 fs 中同名的 flag，命令行已指定的 flag 不会被覆盖
+// 会检查全部参数，并一次性返回所有未知、越界或无效的参数
+func Apply(fs *flag.FlagSet, values map[string]any, rules map[string]Rule) error {
+	set := make(map[string]bool) // 命令行已指定的参数
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []string
+	for _, key := range keys {
+		if key == profileKey || key == profilesKey { // Load 已移除顶层的 profile/profiles，此时只可能来自配置档
+			errs = append(errs, fmt.Sprintf("配置档中不能指定参数 [%s]", key))
+			continue
+		}
+		f := fs.Lookup(key)
+		if f == nil || key == "config" {
+			errs = append(errs, fmt.Sprintf("未知参数 [%s]", key))
+			continue
+		}
+		value, err := toString(values[key])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("参数 [%s] %v", key, err))
+			continue
+		}
+		if rule, ok := rules[key]; ok {
+			if err = rule(value); err != nil {
+				errs = append(errs, fmt.Sprintf("参数 [%s] 的值 [%s] %v", key, value, err))
+				continue
+			}
+		}
+		if set[key] { // 命令行参数优先
+			continue
+		}
+		if err = f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Sprintf("参数 [%s] 的值 [%s] 无效：%v", key, value, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// toString 将配置文件中的值转换为命令行参数形式，列表以英文逗号连接
+func toString(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := toString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("的值类型 [%T] 不支持", v)
+}
+
+// IntRange 要求值为 min ~ max 之间的整数
+func IntRange(min, max int) Rule {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("不是整数")
+		}
+		if n < min || n > max {
+			return fmt.Errorf("超出范围 %d ~ %d", min, max)
+		}
+		return nil
+	}
+}
+
+// FloatRange 要求值为 min ~ max 之间的数字
+func FloatRange(min, max float64) Rule {
+	return func(value string) error {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("不是数字")
+		}
+		if n < min || n > max {
+			return fmt.Errorf("超出范围 %g ~ %g", min, max)
+		}
+		return nil
+	}
+}
+
+// MinDuration 要求值为不小于 min 的时长（如 30m）
+func MinDuration(min time.Duration) Rule {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("不是有效的时长（如 30m）")
+		}
+		if d < min {
+			return fmt.Errorf("不能小于 %s", min)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `
+n: 500
+tl: 200
+f: ip.txt
+profile: home-v4
+profiles:
+  home-v4:
+    tp: 443
+    cfrecord: [a.example.com, b.example.com]
+  office-v6:
+    f: ipv6.txt
+    tp: 8443
+`
+
+const testTOML = `
+n = 500
+tl = 200
+f = "ip.txt"
+
+[profiles.office-v6]
+f = "ipv6.txt"
+tp = 8443
+dd = true
+`
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testFlags 创建与命令行参数同名的 flag
+type testFlags struct {
+	fs       *flag.FlagSet
+	n, tl    int
+	tp       int
+	f        string
+	cfrecord string
+	dd       bool
+	interval time.Duration
+}
+
+func newTestFlags(args ...string) *testFlags {
+	tf := &testFlags{fs: flag.NewFlagSet("test", flag.ContinueOnError)}
+	tf.fs.IntVar(&tf.n, "n", 200, "")
+	tf.fs.IntVar(&tf.tl, "tl", 9999, "")
+	tf.fs.IntVar(&tf.tp, "tp", 443, "")
+	tf.fs.StringVar(&tf.f, "f", "ip.txt", "")
+	tf.fs.StringVar(&tf.cfrecord, "cfrecord", "", "")
+	tf.fs.BoolVar(&tf.dd, "dd", false, "")
+	tf.fs.DurationVar(&tf.interval, "interval", 0, "")
+	tf.fs.String("config", "", "")
+	tf.fs.String("profile", "", "")
+	_ = tf.fs.Parse(args)
+	return tf
+}
+
+// TestLoad_YAML 测试读取 YAML 配置文件及默认配置档
+func TestLoad_YAML(t *testing.T) {
+	path := writeConfig(t, "cfst.yaml", testYAML)
+
+	tf := newTestFlags()
+	values, err := Load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Apply(tf.fs, values, nil); err != nil {
+		t.Fatal(err)
+	}
+	if tf.n != 500 || tf.tl != 200 || tf.tp != 443 || tf.cfrecord != "a.example.com,b.example.com" {
+		t.Errorf("unexpected values: %+v", tf)
+	}
+}
+
+// TestLoad_Profile 测试指定配置档覆盖通用参数，命令行参数优先
+func TestLoad_Profile(t *testing.T) {
+	for _, name := range []string{"cfst.yaml", "cfst.toml"} {
+		content := testYAML
+		if strings.HasSuffix(name, ".toml") {
+			content = testTOML
+		}
+		path := writeConfig(t, name, content)
+
+		tf := newTestFlags("-n", "100")
+		values, err := Load(path, "office-v6")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err = Apply(tf.fs, values, nil); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if tf.n != 100 {
+			t.Errorf("%s: n = %d, expected command line value 100", name, tf.n)
+		}
+		if tf.f != "ipv6.txt" || tf.tp != 8443 || tf.tl != 200 {
+			t.Errorf("%s: unexpected values: %+v", name, tf)
+		}
+	}
+}
+
+// TestLoad_Errors 测试配置文件及配置档错误
+func TestLoad_Errors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), ""); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := Load(writeConfig(t, "bad.yaml", "n: [1"), ""); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+	_, err := Load(writeConfig(t, "cfst.yaml", testYAML), "nope")
+	if err == nil || !strings.Contains(err.Error(), "home-v4, office-v6") {
+		t.Errorf("expected error listing available profiles, got %v", err)
+	}
+}
+
+// TestApply_NestedProfile 测试配置档中指定 profile/profiles 时报错，而不是静默忽略
+func TestApply_NestedProfile(t *testing.T) {
+	path := writeConfig(t, "cfst.yaml", `
+profiles:
+  home:
+    tp: 443
+    profile: office
+    profiles:
+      office:
+        tp: 8443
+`)
+	values, err := Load(path, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Apply(newTestFlags().fs, values, nil)
+	if err == nil {
+		t.Fatal("expected error for profile inside a profile")
+	}
+	for _, want := range []string{"[profile]", "[profiles]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+// TestApply_Validation 测试未知参数、越界参数及无效值一次性报告
+func TestApply_Validation(t *testing.T) {
+	tf := newTestFlags()
+	values := map[string]any{
+		"n":        5000,
+		"tl":       200,
+		"unknown":  1,
+		"config":   "other.yaml",
+		"interval": "-1m",
+		"tp":       "abc",
+		"f":        map[string]any{"a": 1},
+	}
+	rules := map[string]Rule{
+		"n":        IntRange(1, 1000),
+		"tl":       IntRange(0, 9999),
+		"interval": MinDuration(0),
+	}
+	err := Apply(tf.fs, values, rules)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"[unknown]", "[config]", "[n] 的值 [5000] 超出范围", "[interval]", "[tp] 的值 [abc] 无效", "[f] 的值类型"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+	if tf.tl != 200 {
+		t.Errorf("valid value tl not applied: %d", tf.tl)
+	}
+	if tf.n != 200 {
+		t.Errorf("out-of-range value n applied: %d", tf.n)
+	}
+}
@@ -0,0 +1,234 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// DefaultPath 默认的测速历史记录文件
+const DefaultPath = "cfst_history.db"
+
+// openTimeout 等待其他进程释放历史记录文件锁的最长时间
+const openTimeout = 5 * time.Second
+
+var (
+	runsBucket = []byte("runs") // 各次测速：测速时间 → 该次测速结果的 IP 数量
+	ipsBucket  = []byte("ips")  // 各 IP 的测速结果：IP → (测速时间 → 测速结果 JSON)
+)
+
+// Record 单个 IP 在某次测速中的结果，Time 相同的记录属于同一次测速
+type Record struct {
+	Time time.Time `json:"time"` // 测速完成时间
+	utils.IPRecord
+}
+
+// downloaded 返回该次测速是否对该 IP 进行了下载测速
+// 旧版本的记录没有 download_tested 字段，下载速度大于 0 时视为进行了下载测速
+func (r Record) downloaded() bool {
+	return r.Downloaded || r.DownloadSpeed > 0
+}
+
+// DB 测速历史记录：保存在本地 bbolt 数据库文件中，按 IP 及测速时间索引
+// 每次读写时打开文件，完成后立即关闭，以便多个进程（如守护模式与 cfst history 查询）共用同一文件
+type DB struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Open 打开指定路径的历史记录文件，文件在首次写入时创建
+func Open(path string) *DB {
+	return &DB{path: path}
+}
+
+// Path 返回历史记录文件路径
+func (db *DB) Path() string {
+	return db.path
+}
+
+// update 在读写事务中执行 fn，文件不存在时创建
+func (db *DB) update(fn func(tx *bolt.Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	bdb, err := bolt.Open(db.path, 0644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return fmt.Errorf("打开历史记录文件[%s]失败：%w", db.path, err)
+	}
+	defer bdb.Close()
+	if err = bdb.Update(fn); err != nil {
+		return fmt.Errorf("写入历史记录文件[%s]失败：%w", db.path, err)
+	}
+	return nil
+}
+
+// view 在只读事务中执行 fn，文件不存在时不执行（视为没有历史记录）
+func (db *DB) view(fn func(tx *bolt.Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, err := os.Stat(db.path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	bdb, err := bolt.Open(db.path, 0644, &bolt.Options{Timeout: openTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("打开历史记录文件[%s]失败：%w", db.path, err)
+	}
+	defer bdb.Close()
+	if err = bdb.View(fn); err != nil {
+		return fmt.Errorf("读取历史记录文件[%s]失败：%w", db.path, err)
+	}
+	return nil
+}
+
+// Add 写入一次测速的全部结果，t 为该次测速的完成时间
+func (db *DB) Add(t time.Time, data []utils.CloudflareIPData) error {
+	if len(data) == 0 {
+		return nil
+	}
+	key := timeKey(t)
+	return db.update(func(tx *bolt.Tx) error {
+		runs, err := tx.CreateBucketIfNotExists(runsBucket)
+		if err != nil {
+			return err
+		}
+		ips, err := tx.CreateBucketIfNotExists(ipsBucket)
+		if err != nil {
+			return err
+		}
+		for i := range data {
+			rec := data[i].Record()
+			value, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			b, err := ips.CreateBucketIfNotExists([]byte(rec.IP))
+			if err != nil {
+				return err
+			}
+			if err = b.Put(key, value); err != nil {
+				return err
+			}
+		}
+		return runs.Put(key, binary.BigEndian.AppendUint32(nil, uint32(len(data))))
+	})
+}
+
+// Records 读取全部历史记录（按测速时间升序）
+func (db *DB) Records() ([]Record, error) {
+	var records []Record
+	err := db.view(func(tx *bolt.Tx) error {
+		ips := tx.Bucket(ipsBucket)
+		if ips == nil {
+			return nil
+		}
+		return ips.ForEachBucket(func(ip []byte) error {
+			ipRecords, err := readIP(ips.Bucket(ip), string(ip), time.Time{})
+			records = append(records, ipRecords...)
+			return err
+		})
+	})
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, err
+}
+
+// Runs 返回各次测速的时间（升序）
+func (db *DB) Runs() ([]time.Time, error) {
+	var runs []time.Time
+	err := db.view(func(tx *bolt.Tx) error {
+		runs = readRuns(tx, 0)
+		return nil
+	})
+	return runs, err
+}
+
+// IPRecords 返回指定 IP 在 since（含）之后的记录（按测速时间升序），只读取该 IP 的索引
+func (db *DB) IPRecords(ip string, since time.Time) ([]Record, error) {
+	var records []Record
+	err := db.view(func(tx *bolt.Tx) error {
+		var err error
+		if ips := tx.Bucket(ipsBucket); ips != nil {
+			records, err = readIP(ips.Bucket([]byte(ip)), ip, since)
+		}
+		return err
+	})
+	return records, err
+}
+
+// Recent 返回最近 n 次测速的时间（升序）及其中指定 IP 的记录，n <= 0 时返回全部测速
+func (db *DB) Recent(n int, ips []string) (runs []time.Time, past map[string][]Record, err error) {
+	past = make(map[string][]Record, len(ips))
+	err = db.view(func(tx *bolt.Tx) error {
+		runs = readRuns(tx, n)
+		bucket := tx.Bucket(ipsBucket)
+		if len(runs) == 0 || bucket == nil {
+			return nil
+		}
+		for _, ip := range ips {
+			records, err := readIP(bucket.Bucket([]byte(ip)), ip, runs[0])
+			if err != nil {
+				return err
+			}
+			if len(records) > 0 {
+				past[ip] = records
+			}
+		}
+		return nil
+	})
+	return runs, past, err
+}
+
+// readRuns 读取最近 n 次测速的时间（升序），n <= 0 时读取全部
+func readRuns(tx *bolt.Tx, n int) []time.Time {
+	b := tx.Bucket(runsBucket)
+	if b == nil {
+		return nil
+	}
+	var runs []time.Time
+	c := b.Cursor()
+	for k, _ := c.Last(); k != nil && (n <= 0 || len(runs) < n); k, _ = c.Prev() {
+		runs = append(runs, keyTime(k))
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs
+}
+
+// readIP 读取单个 IP 在 since（含）之后的记录，b 为空时返回空
+func readIP(b *bolt.Bucket, ip string, since time.Time) ([]Record, error) {
+	if b == nil {
+		return nil, nil
+	}
+	var records []Record
+	c := b.Cursor()
+	for k, v := c.Seek(timeKey(since)); k != nil; k, v = c.Next() {
+		r := Record{Time: keyTime(k)}
+		if err := json.Unmarshal(v, &r.IPRecord); err != nil {
+			return nil, fmt.Errorf("IP %s 在 %s 的记录解析失败：%w", ip, r.Time.Local().Format("2006-01-02 15:04:05"), err)
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// timeKey 将测速时间编码为按时间排序的键（Unix 纳秒，大端序），同一秒内完成的多次测速不会互相覆盖
+func timeKey(t time.Time) []byte {
+	ns := t.UnixNano()
+	if t.Unix() < 0 || ns < 0 {
+		ns = 0
+	}
+	return binary.BigEndian.AppendUint64(nil, uint64(ns))
+}
+
+func keyTime(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k))).UTC()
+}
@@ -0,0 +1,193 @@
+package history
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+func testData(ip string, recv int, delay time.Duration, speedMB float64) utils.CloudflareIPData {
+	return utils.CloudflareIPData{
+		PingData:      &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP(ip)}, Sended: 4, Received: recv, Delay: delay},
+		DownloadSpeed: speedMB * 1024 * 1024,
+		Downloaded:    speedMB > 0,
+	}
+}
+
+// TestDB_AddRecords 测试写入并读取历史记录
+func TestDB_AddRecords(t *testing.T) {
+	db := Open(filepath.Join(t.TempDir(), DefaultPath))
+	records, err := db.Records()
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Records() of missing file = %v, %v, want empty", records, err)
+	}
+
+	t1 := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	if err = db.Add(t1, []utils.CloudflareIPData{testData("1.1.1.1", 4, 100*time.Millisecond, 10), testData("2.2.2.2", 3, 150*time.Millisecond, 5)}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if err = db.Add(t2, []utils.CloudflareIPData{testData("1.1.1.1", 4, 120*time.Millisecond, 8)}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if records, err = db.Records(); err != nil {
+		t.Fatalf("Records error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("len(records) = %d, want 3", len(records))
+	}
+	if r := records[1]; r.IP != "2.2.2.2" || !r.Time.Equal(t1) || r.LossRate != 0.25 || r.Delay != 150 || r.DownloadSpeed != 5 {
+		t.Errorf("records[1] = %+v", r)
+	}
+	if runs := Runs(records); len(runs) != 2 || !runs[1].Equal(t2) {
+		t.Errorf("Runs() = %v, want [%v %v]", runs, t1, t2)
+	}
+}
+
+// TestDB_Add_SameSecond 测试同一秒内完成的两次测速分别记录，不会互相覆盖
+func TestDB_Add_SameSecond(t *testing.T) {
+	db := Open(filepath.Join(t.TempDir(), DefaultPath))
+	t1 := time.Date(2024, 1, 1, 8, 0, 0, 100*int(time.Millisecond), time.UTC)
+	t2 := t1.Add(500 * time.Millisecond)
+	for i, ts := range []time.Time{t1, t2} {
+		if err := db.Add(ts, []utils.CloudflareIPData{testData("1.1.1.1", 4, time.Duration(i+1)*100*time.Millisecond, 0)}); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+	}
+
+	runs, err := db.Runs()
+	if err != nil || len(runs) != 2 || !runs[0].Equal(t1) || !runs[1].Equal(t2) {
+		t.Errorf("Runs() = %v, %v, want [%v %v]", runs, err, t1, t2)
+	}
+	records, err := db.IPRecords("1.1.1.1", time.Time{})
+	if err != nil || len(records) != 2 || records[0].Delay != 100 || records[1].Delay != 200 {
+		t.Errorf("IPRecords() = %+v, %v, want both runs", records, err)
+	}
+}
+
+// TestDB_Recent 测试只读取最近几次测速中指定 IP 的记录
+func TestDB_Recent(t *testing.T) {
+	db := Open(filepath.Join(t.TempDir(), DefaultPath))
+	runs, past, err := db.Recent(2, []string{"1.1.1.1"})
+	if err != nil || len(runs) != 0 || len(past) != 0 {
+		t.Fatalf("Recent() of missing file = %v, %v, %v, want empty", runs, past, err)
+	}
+
+	t1 := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i, ips := range [][]string{{"1.1.1.1", "2.2.2.2"}, {"2.2.2.2"}, {"1.1.1.1", "3.3.3.3"}} {
+		var data []utils.CloudflareIPData
+		for _, ip := range ips {
+			data = append(data, testData(ip, 4, time.Duration(i+1)*10*time.Millisecond, 0))
+		}
+		if err = db.Add(t1.Add(time.Duration(i)*time.Hour), data); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+	}
+
+	runs, past, err = db.Recent(2, []string{"1.1.1.1", "2.2.2.2", "4.4.4.4"})
+	if err != nil {
+		t.Fatalf("Recent error: %v", err)
+	}
+	if len(runs) != 2 || !runs[0].Equal(t1.Add(time.Hour)) || !runs[1].Equal(t1.Add(2*time.Hour)) {
+		t.Errorf("runs = %v, want the last 2 runs", runs)
+	}
+	if got := past["1.1.1.1"]; len(got) != 1 || got[0].Delay != 30 {
+		t.Errorf("past[1.1.1.1] = %+v, want the record of the last run", got)
+	}
+	if got := past["2.2.2.2"]; len(got) != 1 || got[0].Delay != 20 {
+		t.Errorf("past[2.2.2.2] = %+v", got)
+	}
+	if _, ok := past["4.4.4.4"]; ok || len(past) != 2 {
+		t.Errorf("past = %v, want only 1.1.1.1 and 2.2.2.2", past)
+	}
+
+	records, err := db.IPRecords("1.1.1.1", time.Time{})
+	if err != nil || len(records) != 2 || !records[0].Time.Equal(t1) {
+		t.Errorf("IPRecords() = %+v, %v", records, err)
+	}
+}
+
+// TestDB_Records_Invalid 测试历史记录文件不是有效的数据库时返回错误
+func TestDB_Records_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	if err := os.WriteFile(path, []byte("{\"ip\":\"1.1.1.1\"}\n\nnot json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path).Records(); err == nil {
+		t.Fatal("Records() error = nil, want invalid database error")
+	}
+}
+
+func record(run int, ip string, loss, delay, speed float64) Record {
+	r := Record{Time: time.Date(2024, 1, 1, run, 0, 0, 0, time.UTC)}
+	r.IP, r.LossRate, r.Delay, r.DownloadSpeed, r.Downloaded = ip, loss, delay, speed, speed > 0
+	return r
+}
+
+// failedRecord 返回进行了下载测速但下载失败的记录
+func failedRecord(run int, ip string) Record {
+	r := record(run, ip, 0, 50, 0)
+	r.Downloaded = true
+	return r
+}
+
+// TestSummarize 测试按 IP 统计中位数并按稳定性排序
+func TestSummarize(t *testing.T) {
+	records := []Record{
+		record(1, "1.1.1.1", 0, 20, 10),
+		record(1, "2.2.2.2", 0, 50, 8),
+		record(1, "3.3.3.3", 0, 10, 20),
+		record(2, "1.1.1.1", 0, 20, 12),
+		record(2, "2.2.2.2", 0, 52, 9),
+		record(3, "1.1.1.1", 0, 300, 11),
+		record(3, "2.2.2.2", 0, 51, 7),
+	}
+	summaries := Summarize(records)
+	var order []string
+	for _, s := range summaries {
+		order = append(order, s.IP)
+	}
+	// 2.2.2.2 延迟更平稳；3.3.3.3 只出现过一次
+	want := []string{"2.2.2.2", "1.1.1.1", "3.3.3.3"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	s := summaries[1]
+	if s.Runs != 3 || s.TotalRuns != 3 || s.MedianDelay != 20 || s.MedianSpeed != 11 {
+		t.Errorf("summary of 1.1.1.1 = %+v", s)
+	}
+	if p := summaries[2].Presence(); p < 0.33 || p > 0.34 {
+		t.Errorf("Presence() of 3.3.3.3 = %v, want 1/3", p)
+	}
+
+	// 只统计最近 2 次测速
+	last := Last(records, 2)
+	if len(Runs(last)) != 2 || len(last) != 4 {
+		t.Errorf("Last(records, 2) = %v", last)
+	}
+	if got := ByIP(last, "3.3.3.3"); len(got) != 0 {
+		t.Errorf("ByIP(last, 3.3.3.3) = %v, want empty", got)
+	}
+}
+
+// TestMedian 测试中位数及标准差
+func TestMedian(t *testing.T) {
+	if got := Median([]float64{3, 1, 2}); got != 2 {
+		t.Errorf("Median(odd) = %v, want 2", got)
+	}
+	if got := Median([]float64{4, 1, 3, 2}); got != 2.5 {
+		t.Errorf("Median(even) = %v, want 2.5", got)
+	}
+	if got := StdDev([]float64{2, 4, 4, 4, 5, 5, 7, 9}); got != 2 {
+		t.Errorf("StdDev() = %v, want 2", got)
+	}
+	if Median(nil) != 0 || StdDev(nil) != 0 {
+		t.Error("Median/StdDev of empty values should be 0")
+	}
+}
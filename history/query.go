@@ -0,0 +1,162 @@
+package history
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Summary 单个 IP 在多次测速中的统计
+type Summary struct {
+	IP          string
+	Runs        int       // 出现在测速结果中的次数
+	TotalRuns   int       // 统计范围内的测速次数
+	LossRate    float64   // 平均丢包率
+	MedianDelay float64   // 平均延迟的中位数（毫秒）
+	DelayStdDev float64   // 平均延迟的标准差（毫秒），越小越稳定
+	MedianSpeed float64   // 下载速度的中位数（MB/s），只统计进行了下载测速的记录
+	Colo        string    // 最近一次的地区码
+	LastSeen    time.Time // 最近一次出现的时间
+}
+
+// Presence 返回出现率（出现次数 / 测速次数）
+func (s Summary) Presence() float64 {
+	if s.TotalRuns == 0 {
+		return 0
+	}
+	return float64(s.Runs) / float64(s.TotalRuns)
+}
+
+// Runs 返回各次测速的时间（升序）
+func Runs(records []Record) []time.Time {
+	seen := make(map[time.Time]bool)
+	var runs []time.Time
+	for _, r := range records {
+		if !seen[r.Time] {
+			seen[r.Time] = true
+			runs = append(runs, r.Time)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Before(runs[j]) })
+	return runs
+}
+
+// Last 只保留最近 n 次测速的记录，n <= 0 时返回全部记录
+func Last(records []Record, n int) []Record {
+	runs := Runs(records)
+	if n <= 0 || n >= len(runs) {
+		return records
+	}
+	since := runs[len(runs)-n]
+	var result []Record
+	for _, r := range records {
+		if !r.Time.Before(since) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// ByIP 返回指定 IP 的记录（按测速时间升序）
+func ByIP(records []Record, ip string) []Record {
+	var result []Record
+	for _, r := range records {
+		if r.IP == ip {
+			result = append(result, r)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	return result
+}
+
+// Summarize 按 IP 统计记录，结果按稳定性排序：
+// 出现率高的优先 → 平均丢包率低的优先 → 延迟波动小的优先 → 延迟中位数低的优先
+func Summarize(records []Record) []Summary {
+	totalRuns := len(Runs(records))
+	groups := make(map[string][]Record)
+	var ips []string
+	for _, r := range records {
+		if _, ok := groups[r.IP]; !ok {
+			ips = append(ips, r.IP)
+		}
+		groups[r.IP] = append(groups[r.IP], r)
+	}
+
+	summaries := make([]Summary, 0, len(ips))
+	for _, ip := range ips {
+		summaries = append(summaries, summarize(ip, ByIP(groups[ip], ip), totalRuns))
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Presence() != b.Presence() {
+			return a.Presence() > b.Presence()
+		}
+		if a.LossRate != b.LossRate {
+			return a.LossRate < b.LossRate
+		}
+		if a.DelayStdDev != b.DelayStdDev {
+			return a.DelayStdDev < b.DelayStdDev
+		}
+		return a.MedianDelay < b.MedianDelay
+	})
+	return summaries
+}
+
+// summarize 统计单个 IP 的记录，records 需按时间升序
+func summarize(ip string, records []Record, totalRuns int) Summary {
+	runs := make(map[time.Time]bool)
+	delays := make([]float64, 0, len(records))
+	speeds := make([]float64, 0, len(records))
+	var loss float64
+	for _, r := range records {
+		runs[r.Time] = true
+		delays = append(delays, r.Delay)
+		if r.downloaded() {
+			speeds = append(speeds, r.DownloadSpeed)
+		}
+		loss += r.LossRate
+	}
+	last := records[len(records)-1]
+	return Summary{
+		IP:          ip,
+		Runs:        len(runs),
+		TotalRuns:   totalRuns,
+		LossRate:    loss / float64(len(records)),
+		MedianDelay: Median(delays),
+		DelayStdDev: StdDev(delays),
+		MedianSpeed: Median(speeds),
+		Colo:        last.Colo,
+		LastSeen:    last.Time,
+	}
+}
+
+// Median 返回中位数，values 为空时返回 0
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// StdDev 返回总体标准差，values 为空时返回 0
+func StdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,138 @@
+package history
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+const (
+	scoreDecay       = 0.8    // 每早一次测速，该次结果的权重乘以该系数（本次测速权重为 1）
+	delayFailPenalty = 1000.0 // 按延迟评分时，失败率为 100% 的 IP 额外增加的延迟（毫秒）
+)
+
+// Score 单个 IP 结合历史记录的稳定性评分
+type Score struct {
+	IP       string
+	Value    float64 // 评分，越小越好
+	Samples  int     // 参与评分的测速次数（含本次）
+	FailRate float64 // 加权失败率（丢包或下载速度为 0 的测速占比）
+	Presence float64 // 加权出现率（本次及历史各次测速中包含该 IP 的占比）
+}
+
+// 单次测速的样本
+type sample struct {
+	value  float64 // 延迟（毫秒）或下载速度（MB/s）
+	weight float64 // 权重
+	failed bool    // 是否失败
+}
+
+// Rank 结合历史记录计算本次测速结果中各 IP 的稳定性评分，并按评分对 data 排序（原地）
+// runs 为历史测速的时间（升序，不含本次测速），past 为其中各 IP 的记录（按时间升序），越近的测速权重越高
+// 有效率 = (1 - 失败率) × 出现率，仅出现过一次的 IP 不会排在多次测速均表现良好的 IP 之前
+// byDelay 为 true 时按延迟评分：加权中位数 + 加权标准差 + (1 - 有效率) × 失败惩罚，越低越好
+// 否则按下载速度评分：(加权中位数 - 加权标准差) × 有效率，越高越好，未进行下载测速的测速不参与评分
+// 返回值与排序后的 data 一一对应
+func Rank(data utils.DownloadSpeedSet, runs []time.Time, past map[string][]Record, byDelay bool) []Score {
+	weights := runWeights(runs)
+	totalWeight := 1.0 // 本次测速权重为 1
+	for _, w := range weights {
+		totalWeight += w
+	}
+	scores := make(map[string]Score, len(data))
+	for i := range data {
+		rec := data[i].Record()
+		var samples []sample
+		if byDelay || rec.Downloaded {
+			samples = append(samples, newSample(rec, 1, byDelay))
+		}
+		present := 1.0
+		for _, r := range past[rec.IP] {
+			present += weights[r.Time]
+			if byDelay || r.downloaded() {
+				samples = append(samples, newSample(r.IPRecord, weights[r.Time], byDelay))
+			}
+		}
+		scores[rec.IP] = score(rec.IP, samples, math.Min(present/totalWeight, 1), byDelay)
+	}
+
+	sort.SliceStable(data, func(i, j int) bool {
+		return scores[data[i].IP.String()].Value < scores[data[j].IP.String()].Value
+	})
+	result := make([]Score, 0, len(data))
+	for i := range data {
+		result = append(result, scores[data[i].IP.String()])
+	}
+	return result
+}
+
+// runWeights 按测速时间计算各次历史测速的权重，最近一次为 scoreDecay，依次递减
+func runWeights(runs []time.Time) map[time.Time]float64 {
+	weights := make(map[time.Time]float64, len(runs))
+	w := 1.0
+	for i := len(runs) - 1; i >= 0; i-- {
+		w *= scoreDecay
+		weights[runs[i]] = w
+	}
+	return weights
+}
+
+func newSample(r utils.IPRecord, weight float64, byDelay bool) sample {
+	s := sample{weight: weight, failed: r.LossRate > 0}
+	if byDelay {
+		s.value = r.Delay
+	} else {
+		s.value = r.DownloadSpeed
+		s.failed = s.failed || r.DownloadSpeed == 0
+	}
+	return s
+}
+
+// score 根据样本及出现率计算评分，没有样本（从未进行下载测速）时评分为 0，排在有下载速度的 IP 之后
+func score(ip string, samples []sample, presence float64, byDelay bool) Score {
+	if len(samples) == 0 {
+		return Score{IP: ip, Presence: presence}
+	}
+	var total, failed float64
+	for _, s := range samples {
+		total += s.weight
+		if s.failed {
+			failed += s.weight
+		}
+	}
+	median, stddev := weightedStats(samples)
+	sc := Score{IP: ip, Samples: len(samples), FailRate: failed / total, Presence: presence}
+	valid := (1 - sc.FailRate) * presence
+	if byDelay {
+		sc.Value = median + stddev + (1-valid)*delayFailPenalty
+	} else {
+		sc.Value = -math.Max(median-stddev, 0) * valid
+	}
+	return sc
+}
+
+// weightedStats 返回样本的加权中位数与加权标准差
+func weightedStats(samples []sample) (median, stddev float64) {
+	sorted := append([]sample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+	var total, mean float64
+	for _, s := range sorted {
+		total += s.weight
+		mean += s.value * s.weight
+	}
+	mean /= total
+
+	var cum, variance float64
+	median = sorted[len(sorted)-1].value
+	found := false
+	for _, s := range sorted {
+		cum += s.weight
+		if !found && cum >= total/2 {
+			median, found = s.value, true
+		}
+		variance += s.weight * (s.value - mean) * (s.value - mean)
+	}
+	return median, math.Sqrt(variance / total)
+}
@@ -0,0 +1,138 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+func rankIPs(data utils.DownloadSpeedSet) []string {
+	ips := make([]string, 0, len(data))
+	for _, v := range data {
+		ips = append(ips, v.IP.String())
+	}
+	return ips
+}
+
+// rank 将按时间升序的历史记录按 IP 分组后调用 Rank
+func rank(data utils.DownloadSpeedSet, past []Record, byDelay bool) []Score {
+	byIP := make(map[string][]Record)
+	for _, r := range past {
+		byIP[r.IP] = append(byIP[r.IP], r)
+	}
+	return Rank(data, Runs(past), byIP, byDelay)
+}
+
+// TestRank_ByDelay 测试按延迟评分：历史波动大、经常丢包的 IP 排在后面，仅出现一次的 IP 不会排在一直稳定的 IP 之前
+func TestRank_ByDelay(t *testing.T) {
+	// 本次测速 1.1.1.1 延迟最低，但历史上波动大且丢包
+	data := utils.DownloadSpeedSet{
+		testData("1.1.1.1", 4, 20*time.Millisecond, 0),
+		testData("2.2.2.2", 4, 40*time.Millisecond, 0),
+		testData("3.3.3.3", 4, 30*time.Millisecond, 0),
+	}
+	past := []Record{
+		record(1, "1.1.1.1", 0.25, 300, 0),
+		record(1, "2.2.2.2", 0, 41, 0),
+		record(2, "1.1.1.1", 0.5, 250, 0),
+		record(2, "2.2.2.2", 0, 39, 0),
+	}
+	scores := rank(data, past, true)
+	want := []string{"2.2.2.2", "3.3.3.3", "1.1.1.1"}
+	got := rankIPs(data)
+	for i := range want {
+		if got[i] != want[i] || scores[i].IP != want[i] {
+			t.Fatalf("Rank() order = %v, want %v", got, want)
+		}
+	}
+	if scores[0].Samples != 3 || scores[1].Samples != 1 {
+		t.Errorf("Samples = %d, %d, want 3, 1", scores[0].Samples, scores[1].Samples)
+	}
+	if scores[2].FailRate <= 0 || scores[0].FailRate != 0 {
+		t.Errorf("FailRate = %v, %v", scores[2].FailRate, scores[0].FailRate)
+	}
+	if scores[0].Presence != 1 || scores[1].Presence >= 0.5 {
+		t.Errorf("Presence = %v, %v, want 1 and < 0.5", scores[0].Presence, scores[1].Presence)
+	}
+}
+
+// TestRank_BySpeed 测试按下载速度评分：历史下载失败计为失败
+func TestRank_BySpeed(t *testing.T) {
+	data := utils.DownloadSpeedSet{
+		testData("1.1.1.1", 4, 50*time.Millisecond, 20),
+		testData("2.2.2.2", 4, 50*time.Millisecond, 15),
+	}
+	past := []Record{
+		failedRecord(1, "1.1.1.1"),
+		record(1, "2.2.2.2", 0, 50, 16),
+		failedRecord(2, "1.1.1.1"),
+		record(2, "2.2.2.2", 0, 50, 14),
+	}
+	scores := rank(data, past, false)
+	if got := rankIPs(data); got[0] != "2.2.2.2" {
+		t.Fatalf("Rank() order = %v, want 2.2.2.2 first", got)
+	}
+	if scores[1].FailRate < 0.5 {
+		t.Errorf("FailRate of 1.1.1.1 = %v, want >= 0.5", scores[1].FailRate)
+	}
+
+	// 没有历史记录时与本次测速的速度排序一致
+	data = utils.DownloadSpeedSet{
+		testData("2.2.2.2", 4, 50*time.Millisecond, 15),
+		testData("1.1.1.1", 4, 50*time.Millisecond, 20),
+	}
+	rank(data, nil, false)
+	if got := rankIPs(data); got[0] != "1.1.1.1" {
+		t.Errorf("Rank() without history = %v, want 1.1.1.1 first", got)
+	}
+}
+
+// TestRank_BySpeed_Presence 测试按下载速度评分：仅出现一次的高速 IP 不会排在多次测速均稳定的 IP 之前
+func TestRank_BySpeed_Presence(t *testing.T) {
+	data := utils.DownloadSpeedSet{
+		testData("1.1.1.1", 4, 50*time.Millisecond, 30), // 首次出现
+		testData("2.2.2.2", 4, 50*time.Millisecond, 15),
+	}
+	var past []Record
+	for run := 1; run <= 5; run++ {
+		past = append(past, record(run, "2.2.2.2", 0, 50, 15))
+	}
+	scores := rank(data, past, false)
+	if got := rankIPs(data); got[0] != "2.2.2.2" {
+		t.Fatalf("Rank() order = %v, want 2.2.2.2 first", got)
+	}
+	if scores[0].Presence != 1 || scores[1].Presence >= 0.5 || scores[1].FailRate != 0 {
+		t.Errorf("scores = %+v", scores)
+	}
+}
+
+// TestRank_BySpeed_Untested 测试未进行下载测速（如 -sl 0 时排在 [-dn] 之后）的测速不计为失败
+func TestRank_BySpeed_Untested(t *testing.T) {
+	data := utils.DownloadSpeedSet{
+		testData("1.1.1.1", 4, 50*time.Millisecond, 10),
+		testData("2.2.2.2", 4, 60*time.Millisecond, 0), // 本次未进行下载测速
+		testData("3.3.3.3", 4, 70*time.Millisecond, 0), // 本次及历史均未进行下载测速
+	}
+	past := []Record{
+		record(1, "1.1.1.1", 0, 50, 10),
+		record(1, "2.2.2.2", 0, 60, 20),
+		record(1, "3.3.3.3", 0, 70, 0),
+		record(2, "1.1.1.1", 0, 50, 10),
+		record(2, "2.2.2.2", 0, 60, 20),
+	}
+	scores := rank(data, past, false)
+	want := []string{"2.2.2.2", "1.1.1.1", "3.3.3.3"}
+	got := rankIPs(data)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Rank() order = %v, want %v", got, want)
+		}
+	}
+	if scores[0].FailRate != 0 || scores[0].Samples != 2 {
+		t.Errorf("2.2.2.2 FailRate = %v, Samples = %d, want 0, 2", scores[0].FailRate, scores[0].Samples)
+	}
+	if scores[2].FailRate != 0 || scores[2].Samples != 0 {
+		t.Errorf("3.3.3.3 FailRate = %v, Samples = %d, want 0, 0", scores[2].FailRate, scores[2].Samples)
+	}
+}
@@ -1,23 +1,128 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
 	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/XIU2/CloudflareSpeedTest/config"
+	"github.com/XIU2/CloudflareSpeedTest/history"
+	"github.com/XIU2/CloudflareSpeedTest/publish"
+	"github.com/XIU2/CloudflareSpeedTest/server"
 	"github.com/XIU2/CloudflareSpeedTest/task"
 	"github.com/XIU2/CloudflareSpeedTest/utils"
 )
 
 var (
 	version, versionNew string
+
+	cfg          = task.DefaultConfig() // 测速配置
+	output       string                 // 输出结果文件
+	outputFormat string                 // 输出结果文件格式
+	streamOutput string                 // 实时输出延迟测速结果
+	streamStdout *os.File               // 实时输出到标准输出时的原标准输出
+	domains      string                 // DNS 配置域名（英文逗号分隔）
+	topNum       int                    // DNS 配置 IP 数量
+	printNum     int                    // 显示结果数量
+
+	cfPublisher publish.Cloudflare // 更新 Cloudflare 解析记录
+	cfRecords   string             // Cloudflare 解析记录（英文逗号分隔）
+	cfType      string             // Cloudflare 记录类型
+	cfTTL       int                // Cloudflare 记录 TTL
+	cfProxied   bool               // Cloudflare 代理状态
+	dpPublisher publish.DNSPod     // 更新 DNSPod 解析记录
+	dpRecords   string             // DNSPod 主机记录（英文逗号分隔）
+	dpType      string             // DNSPod 记录类型
+	dpTTL       int                // DNSPod 记录 TTL
+	hostsPub    publish.Hosts      // 更新 hosts 文件
+	hostsDomain string             // hosts 域名（英文逗号分隔）
+	dryRun      bool               // 模拟更新解析记录
+
+	interval  time.Duration // 守护模式测速间隔
+	threshold float64       // 守护模式劣化阈值（百分比）
+	hookCmd   string        // 测速结果更新后执行的命令
+	listen    string        // API 服务监听地址
+
+	configFile  string // 配置文件
+	profile     string // 配置档
+	historyFile string // 测速历史记录文件
+	stable      bool   // 按历史稳定性排序
+	stableRuns  int    // 稳定性评分参考的历史测速次数
 )
 
+// 配置文件中参数的取值范围
+var configRules = map[string]config.Rule{
+	"n":          config.IntRange(1, 1000),
+	"t":          config.IntRange(1, 1000),
+	"dn":         config.IntRange(1, 100000),
+	"dt":         config.IntRange(1, 3600),
+	"dc":         config.IntRange(1, 32),
+	"dconn":      config.IntRange(1, 16),
+	"upsize":     config.IntRange(1, 10240),
+	"tp":         config.IntRange(1, 65535),
+	"tl":         config.IntRange(0, 9999),
+	"tll":        config.IntRange(0, 9999),
+	"tlr":        config.FloatRange(0, 1),
+	"tj":         config.IntRange(0, 9999),
+	"sl":         config.FloatRange(0, 100000),
+	"p":          config.IntRange(0, 100000),
+	"top":        config.IntRange(1, 100000),
+	"cfttl":      config.IntRange(1, 86400),
+	"dpttl":      config.IntRange(0, 604800),
+	"interval":   config.MinDuration(0),
+	"threshold":  config.FloatRange(0, 100),
+	"stableruns": config.IntRange(1, 1000),
+	"proto": func(value string) error {
+		_, err := task.ParseProtocol(value)
+		return err
+	},
+	"H": func(value string) error {
+		return task.ParseHeaders(http.Header{}, value)
+	},
+	"httping-code": func(value string) error {
+		_, err := task.ParseStatusCodes(value)
+		return err
+	},
+	"dcode": func(value string) error {
+		_, err := task.ParseStatusCodes(value)
+		return err
+	},
+	"httping-header": func(value string) error {
+		return task.ParseHeaders(http.Header{}, value)
+	},
+	"httping-regexp": func(value string) error {
+		_, err := regexp.Compile(value)
+		return err
+	},
+	"httping-sha256": func(value string) error {
+		_, err := task.ParseSHA256(value)
+		return err
+	},
+	"httping-minsize": config.IntRange(0, 1<<30),
+	"sort": func(value string) error {
+		_, err := utils.ParseSortOrder(value)
+		return err
+	},
+}
+
 func init() {
+	if len(os.Args) > 1 && os.Args[1] == "history" { // 子命令：查询测速历史记录
+		historyCommand(os.Args[2:])
+		os.Exit(0)
+	}
+
 	var printVersion bool
 	var help = `
 CloudflareSpeedTest ` + version + `
@@ -33,6 +138,19 @@ https://github.com/XIU2/CloudflareSpeedTest
         下载测速数量；延迟测速并排序后，从最低延迟起下载测速的数量；(默认 10 个)
     -dt 10
         下载测速时间；单个 IP 下载测速最长时间，不能太短；(默认 10 秒)
+    -dc 3
+        下载测速并发数；同时下载测速的 IP 数量 (最多 32)，首个 IP 单独测速作为带宽基准，并发测速的总带宽未明显高于单独测速时
+        视为上行带宽已跑满、相互干扰，自动回退为逐个测速并重新测速受干扰的 IP；(默认 1 逐个测速)
+    -dconn 4
+        单 IP 下载连接数；对同一 IP 同时建立多个连接下载 (最多 16)，下载速度为各连接的总速度，适用于单连接受 TCP 窗口限制的高延迟线路；
+        结果文件中另外输出单连接速度 (最快的连接在与其他连接共享带宽时的速度，通常低于单独使用一个连接时的速度)；(默认 1)
+    -upurl https://upload.example.com/upload
+        上传测速地址；指定后在下载测速完成后，对测速结果的前 [-dn] 个 IP 逐个 POST 生成的数据测试上传速度，
+        单个 IP 最长测速 [-dt] 秒，服务器需返回 2xx 状态码；可通过排序键 upload 按上传速度排序；(默认 空 不进行上传测速)
+    -upsize 100
+        上传测速数据量；单个 IP 上传测速发送的数据量 (MB，最多 10240)，上传完成或达到 [-dt] 时间后结束；(默认 100 MB)
+    -dcode 200,206
+        下载测速有效状态代码；下载测速时 (跟随重定向后) 网页返回的有效 HTTP 状态码，格式同 [-httping-code]；(默认 200)
     -tp 443
         指定测速端口；延迟测速/下载测速时使用的端口；(默认 443 端口)
     -url https://cf.xiu2.xyz/url
@@ -40,10 +158,45 @@ https://github.com/XIU2/CloudflareSpeedTest
 
     -httping
         切换测速模式；延迟测速模式改为 HTTP 协议，所用测试地址为 [-url] 参数；(默认 TCPing)
-    -httping-code 200
-        有效状态代码；HTTPing 延迟测速时网页返回的有效 HTTP 状态码，仅限一个；(默认 200 301 302)
+    -httping-code 200,204,3xx
+        有效状态代码；HTTPing 延迟测速时网页返回的有效 HTTP 状态码，英文逗号分隔，支持 2xx 及 200-299 形式的范围，
+        首次请求状态码无效时该 IP 不可用，后续请求状态码无效时视为丢包；被拒绝的状态码及次数输出到结果文件中；(默认 200,301,302)
+    -httping-match "<title>Example</title>"
+        响应体需包含的字符串；HTTPing 延迟测速时响应体不包含该字符串的请求视为失败 (首次请求失败时该 IP 不可用)，
+        用于排除返回验证页面、错误页面等非预期内容的 IP；以下校验参数可搭配使用，需要校验响应体时 HEAD 请求改为 GET；(默认 不校验)
+    -httping-regexp "(?i)ok"
+        响应体需匹配的正则表达式；同上；(默认 不校验)
+    -httping-header "Content-Type: image/svg"
+        响应头需包含的值；响应头的值不包含指定值的请求视为失败，可重复指定多个；(默认 不校验)
+    -httping-minsize 1024
+        响应体最小字节数；响应体小于指定大小的请求视为失败；(默认 0 不校验)
+    -httping-sha256 e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+        响应体的 SHA-256；响应体的 SHA-256 与指定值不一致的请求视为失败；(默认 不校验)
     -cfcolo HKG,KHH,NRT,LAX,SEA,SJC,FRA,MAD
         匹配指定地区；IATA 机场地区码或国家/城市码，英文逗号分隔，仅 HTTPing 模式可用；(默认 所有地区)
+    -tlsping
+        切换测速模式；延迟测速模式改为 TLS 握手，SNI 为 [-url] 参数的域名，延迟为 TCP 连接 + TLS 握手耗时；
+        结果文件中另外输出 TCP 连接耗时、TLS 握手耗时、协商的 TLS 版本及 ALPN、证书对该域名是否有效；不能与 [-httping] 同时使用；(默认 TCPing)
+    -proto h2
+        HTTP 协议版本；HTTPing 延迟测速及下载/上传测速使用的协议，可选 h1 (HTTP/1.1)、h2 (强制 HTTP/2)、h3 (HTTP/3 QUIC，通过 UDP 连接 [-tp] 端口)，h2/h3 需 HTTPS 测速地址，
+        指定 h2/h3 时未协商到该协议的 IP 视为不可用；结果文件中另外输出实际使用的 HTTP 协议；(默认 h1)
+    -sni speed.example.com
+        指定 SNI；延迟测速(HTTPing/TLSPing)、下载及上传测速时 TLS 握手使用的 SNI，用于以自己的域名测试 IP，而从 [-url] 指定的地址下载；
+        (默认 [-host] 参数的域名，未指定时为测速地址的域名)
+    -host speed.example.com
+        指定 Host；HTTPing 延迟测速、下载及上传测速时请求的 Host 头；(默认 测速地址的域名)
+    -insecure
+        跳过证书验证；HTTPing 延迟测速、下载及上传测速时不验证证书，用于自签名证书的测试源站；(默认 验证证书)
+    -H "Authorization: Bearer xxx"
+        自定义请求头；HTTPing 延迟测速、下载及上传测速时携带的请求头，可重复指定多个，指定 User-Agent 时覆盖默认值；(默认 无)
+    -httping-method HEAD
+        HTTPing 请求方法；HTTPing 延迟测速时使用的 HTTP 请求方法；(默认 HEAD)
+    -dm GET
+        下载测速请求方法；下载测速时使用的 HTTP 请求方法；(默认 GET)
+    -body '{"key":"value"}'
+        请求体；HTTPing 延迟测速及下载测速时发送的请求体，以 @ 开头时读取该文件的内容，如 -body @body.json；(默认 空)
+    -cookiejar
+        启用 Cookie；同一 IP 的多次 HTTPing 请求之间及下载测速重定向时保留服务器设置的 Cookie；(默认 关闭)
 
     -tl 200
         平均延迟上限；只输出低于指定平均延迟的 IP，各上下限条件可搭配使用；(默认 9999 ms)
@@ -51,6 +204,14 @@ https://github.com/XIU2/CloudflareSpeedTest
         平均延迟下限；只输出高于指定平均延迟的 IP；(默认 0 ms)
     -tlr 0.2
         丢包几率上限；只输出低于/等于指定丢包率的 IP，范围 0.00~1.00，0 过滤掉任何丢包的 IP；(默认 1.00)
+    -tj 30
+        抖动上限；只输出抖动 (相邻两次延迟之差的平均值) 低于/等于指定值的 IP；(默认 9999 ms)
+    -sj
+        按抖动排序；延迟测速及最终结果按抖动从低到高排序 (抖动相同时保持原有顺序)；(默认 关闭)
+    -sort loss,delay,-speed
+        结果排序方式；英文逗号分隔的排序键，前面的键相同时才比较后面的键，前缀 - 表示从大到小；同时决定下载测速的顺序及最终结果的顺序；
+        可用排序键：loss 丢包率、delay 平均延迟、speed 下载速度、upload 上传速度、colo 地区码、received 已接收、sent 已发送、jitter 抖动、stddev 延迟标准差、min max p50 p90 延迟统计；
+        (默认 延迟测速按 loss,delay，最终结果按 -speed，禁用下载测速时按 loss,delay)
     -sl 5
         下载速度下限；只输出高于指定下载速度的 IP，凑够指定数量 [-dn] 才会停止测速；(默认 0.00 MB/s)
 
@@ -62,12 +223,77 @@ https://github.com/XIU2/CloudflareSpeedTest
         指定IP段数据；直接通过参数指定要测速的 IP 段数据，英文逗号分隔；(默认 空)
     -o result.csv
         写入结果文件；如路径含有空格请加上引号；值为空时不写入文件 [-o ""]；(默认 result.csv)
+    -of json
+        结果文件格式；支持 csv、json、ndjson，未指定时根据 [-o] 文件扩展名判断 (.json .ndjson .jsonl)；(默认 csv)
+        另支持 DNS 配置格式 dnsmasq、unbound、adguard (需搭配 [-domains])，生成将域名指向测速结果前 N 个 IP 的配置片段
+    -domains example.com,www.example.com
+        DNS 配置域名；结果文件格式为 dnsmasq、unbound、adguard 时写入的域名，英文逗号分隔 (同时匹配子域名)；(默认 空)
+    -top 1
+        DNS 配置 IP 数量；结果文件格式为 dnsmasq、unbound、adguard 时写入测速结果的前 N 个 IP；(默认 1)
+    -stream -
+        实时输出结果；每个 IP 延迟测速完成后立即以 NDJSON 格式写入指定文件，值为 - 时输出到标准输出 (其余输出改为标准错误)；(默认 空)
 
     -dd
         禁用下载测速；禁用后测速结果会按延迟排序 (默认按下载速度排序)；(默认 启用)
     -allip
         测速全部的IP；对 IP 段中的每个 IP (仅支持 IPv4) 进行测速；(默认 每个 /24 段随机测速一个 IP)
 
+    -cfzone 023e105f4ecef8ad9ca31a8372d0c353
+        Cloudflare 区域 ID；指定后在测速完成后通过 Cloudflare API 更新解析记录；(默认 空)
+    -cfrecord cdn.example.com,cdn.example.com
+        Cloudflare 解析记录；完整域名，英文逗号分隔，按顺序分配测速结果的前 N 个 IP (同名记录则更新多条)；(默认 空)
+    -cftype A
+        Cloudflare 记录类型；A (IPv4) 或 AAAA (IPv6)；(默认 A)
+    -cftoken xxx
+        Cloudflare API 令牌；也可通过环境变量 CF_API_TOKEN 指定；(默认 空)
+    -cfkey xxx -cfemail xxx
+        Cloudflare API 密钥+邮箱；未指定 API 令牌时使用，也可通过环境变量 CF_API_KEY CF_API_EMAIL 指定；(默认 空)
+    -cfttl 1
+        Cloudflare 记录 TTL；1 为自动；(默认 1)
+    -cfproxied
+        Cloudflare 代理状态；开启后解析记录为 已代理 (橙色云朵)；(默认 关闭)
+    -dpdomain example.com
+        DNSPod 域名；指定后在测速完成后通过 DNSPod API 更新解析记录；(默认 空)
+    -dprecord www,www
+        DNSPod 主机记录；英文逗号分隔，按顺序分配测速结果的前 N 个 IP (同名记录则更新多条)，@ 为根域名；(默认 空)
+    -dptype A
+        DNSPod 记录类型；A (IPv4) 或 AAAA (IPv6)；(默认 A)
+    -dptoken 12345,xxx
+        DNSPod API Token；格式为 ID,Token，也可通过环境变量 DNSPOD_TOKEN 指定；(默认 空)
+    -dpttl 600
+        DNSPod 记录 TTL；为 0 时使用 DNSPod 默认值；(默认 0)
+    -hosts example.com,www.example.com
+        更新 hosts 文件；英文逗号分隔的域名，测速完成后将这些域名指向最优 IP (写入 hosts 文件中由本程序管理的区块，写入前会备份原文件，写入失败则自动回滚)；(默认 空)
+    -hostsfile /etc/hosts
+        hosts 文件路径；备份文件为同目录下的 hosts.cfst_backup；(默认 Linux/Mac 为 /etc/hosts，Windows 为 C:\Windows\System32\drivers\etc\hosts)
+    -dryrun
+        模拟更新解析记录及 hosts 文件；只输出将要进行的修改，不实际更新；(默认 关闭)
+
+    -interval 30m
+        守护模式；按指定间隔重复测速 (支持 s m h 单位)，仅在最优 IP 变化或劣化超过 [-threshold] 时才输出结果文件、发布测速结果及执行 [-hook]；(默认 0 不启用)
+    -threshold 20
+        守护模式劣化阈值；最优 IP 未变化，但其下载速度下降或平均延迟上升超过该百分比时也视为变化；值为 0 时仅在最优 IP 变化时更新；(默认 20)
+    -hook "./update.sh"
+        钩子命令；测速结果更新后执行 (守护模式下仅在最优 IP 变化或劣化时执行)，可通过环境变量 CFST_BEST_IP CFST_IPS CFST_OUTPUT CFST_REASON 获取结果；(默认 空)
+    -listen :8080
+        API 服务；在指定地址提供 HTTP API：POST /scan 开始测速、GET /progress 测速进度、GET /results 最新测速结果 (JSON，可加 ?format=csv 等)、GET /best 最优 IP (纯文本)、GET /metrics Prometheus 指标 (各 IP 延迟/丢包率/下载速度/地区码、测速耗时、IP 数量等)；
+        未搭配 [-interval] 时完成首次测速后继续运行，直到按下 Ctrl+C；(默认 空 不启用)
+
+    -history cfst_history.db
+        记录测速历史；每次测速完成后将结果写入指定的数据库文件 (bbolt 格式，按 IP 及测速时间索引)，被中断的测速不会记录；
+        可通过 [cfst history] 子命令查询各 IP 的历史结果、最近 N 次测速的延迟/速度中位数及最稳定的 IP；(默认 空 不记录)
+    -stable
+        按稳定性排序；结合历史记录中最近 [-stableruns] 次测速结果 (越近权重越高) 计算加权中位数，并扣除波动、历史失败 (丢包、下载失败，未进行下载测速的不计) 及未出现在历次测速中的影响，
+        以此作为最终排序及最优 IP；未指定 [-history] 时使用 cfst_history.db 记录测速历史；(默认 关闭)
+    -stableruns 10
+        稳定性评分参考的历史测速次数；(默认 10 次)
+
+    -config cfst.yaml
+        配置文件；YAML 或 TOML (.toml) 格式，键与参数名称一致 (如 n: 500、tl: 200)，profiles 下可定义多个配置档；
+        命令行参数优先于配置文件，配置文件中的未知参数或越界参数会报错；(默认 空)
+    -profile home-v4
+        配置档；使用配置文件 profiles 下的指定配置档 (覆盖通用参数)，未指定时使用配置文件中 profile 的值；(默认 空)
+
     -debug
         调试输出模式；会在一些非预期情况下输出更多日志以便判断原因；(默认 关闭)
 
@@ -76,46 +302,164 @@ https://github.com/XIU2/CloudflareSpeedTest
     -h
         打印帮助说明
 `
-	var minDelay, maxDelay, downloadTime int
+	var minDelay, maxDelay, maxJitter, downloadTime int
+	var sortExpr, protocol, body string
+	var httpingRegexp, httpingSHA256 string
+	var httpingCode, downloadCode string
+	var uploadSize int
 	var maxLossRate float64
-	flag.IntVar(&task.Routines, "n", 200, "延迟测速线程")
-	flag.IntVar(&task.PingTimes, "t", 4, "延迟测速次数")
-	flag.IntVar(&task.TestCount, "dn", 10, "下载测速数量")
+	flag.IntVar(&cfg.Routines, "n", 200, "延迟测速线程")
+	flag.IntVar(&cfg.PingTimes, "t", 4, "延迟测速次数")
+	flag.IntVar(&cfg.TestCount, "dn", 10, "下载测速数量")
 	flag.IntVar(&downloadTime, "dt", 10, "下载测速时间")
-	flag.IntVar(&task.TCPPort, "tp", 443, "指定测速端口")
-	flag.StringVar(&task.URL, "url", "https://cf.xiu2.xyz/url", "指定测速地址")
+	flag.IntVar(&cfg.DownloadRoutines, "dc", 1, "下载测速并发数")
+	flag.IntVar(&cfg.DownloadConns, "dconn", 1, "单 IP 下载连接数")
+	flag.StringVar(&cfg.UploadURL, "upurl", "", "上传测速地址")
+	flag.IntVar(&uploadSize, "upsize", 100, "上传测速数据量")
+	flag.IntVar(&cfg.TCPPort, "tp", 443, "指定测速端口")
+	flag.StringVar(&cfg.URL, "url", "https://cf.xiu2.xyz/url", "指定测速地址")
 
-	flag.BoolVar(&task.Httping, "httping", false, "切换测速模式")
-	flag.IntVar(&task.HttpingStatusCode, "httping-code", 0, "有效状态代码")
-	flag.StringVar(&task.HttpingCFColo, "cfcolo", "", "匹配指定地区")
+	flag.BoolVar(&cfg.Httping, "httping", false, "切换测速模式")
+	flag.BoolVar(&cfg.TLSPing, "tlsping", false, "切换测速模式")
+	flag.StringVar(&httpingCode, "httping-code", "", "有效状态代码")
+	flag.StringVar(&downloadCode, "dcode", "", "下载测速有效状态代码")
+	flag.StringVar(&cfg.HttpingCFColo, "cfcolo", "", "匹配指定地区")
+	cfg.HttpingCheck.Header = http.Header{}
+	flag.StringVar(&cfg.HttpingCheck.Contains, "httping-match", "", "响应体需包含的字符串")
+	flag.StringVar(&httpingRegexp, "httping-regexp", "", "响应体需匹配的正则表达式")
+	flag.Var(headerFlag(cfg.HttpingCheck.Header), "httping-header", "响应头需包含的值")
+	flag.Int64Var(&cfg.HttpingCheck.MinSize, "httping-minsize", 0, "响应体最小字节数")
+	flag.StringVar(&httpingSHA256, "httping-sha256", "", "响应体的 SHA-256")
+	flag.StringVar(&protocol, "proto", task.ProtocolHTTP1, "HTTP 协议版本")
+	flag.StringVar(&cfg.SNI, "sni", "", "指定 SNI")
+	flag.StringVar(&cfg.Host, "host", "", "指定 Host")
+	flag.BoolVar(&cfg.Insecure, "insecure", false, "跳过证书验证")
+	cfg.Headers = http.Header{}
+	flag.Var(headerFlag(cfg.Headers), "H", "自定义请求头")
+	flag.StringVar(&cfg.HttpingMethod, "httping-method", http.MethodHead, "HTTPing 请求方法")
+	flag.StringVar(&cfg.DownloadMethod, "dm", http.MethodGet, "下载测速请求方法")
+	flag.StringVar(&body, "body", "", "请求体")
+	flag.BoolVar(&cfg.CookieJar, "cookiejar", false, "启用 Cookie")
 
 	flag.IntVar(&maxDelay, "tl", 9999, "平均延迟上限")
 	flag.IntVar(&minDelay, "tll", 0, "平均延迟下限")
 	flag.Float64Var(&maxLossRate, "tlr", 1, "丢包几率上限")
-	flag.Float64Var(&task.MinSpeed, "sl", 0, "下载速度下限")
+	flag.IntVar(&maxJitter, "tj", 9999, "抖动上限")
+	flag.BoolVar(&cfg.SortByJitter, "sj", false, "按抖动排序")
+	flag.StringVar(&sortExpr, "sort", "", "结果排序方式")
+	flag.Float64Var(&cfg.MinSpeed, "sl", 0, "下载速度下限")
+
+	flag.IntVar(&printNum, "p", utils.DefaultPrintNum, "显示结果数量")
+	flag.StringVar(&cfg.IPFile, "f", "ip.txt", "IP段数据文件")
+	flag.StringVar(&cfg.IPText, "ip", "", "指定IP段数据")
+	flag.StringVar(&output, "o", utils.DefaultOutput, "输出结果文件")
+	flag.StringVar(&outputFormat, "of", "", "结果文件格式")
+	flag.StringVar(&streamOutput, "stream", "", "实时输出结果")
+	flag.StringVar(&domains, "domains", "", "DNS 配置域名")
+	flag.IntVar(&topNum, "top", 1, "DNS 配置 IP 数量")
+
+	flag.BoolVar(&cfg.DisableDownload, "dd", false, "禁用下载测速")
+	flag.BoolVar(&cfg.TestAll, "allip", false, "测速全部 IP")
 
-	flag.IntVar(&utils.PrintNum, "p", 10, "显示结果数量")
-	flag.StringVar(&task.IPFile, "f", "ip.txt", "IP段数据文件")
-	flag.StringVar(&task.IPText, "ip", "", "指定IP段数据")
-	flag.StringVar(&utils.Output, "o", "result.csv", "输出结果文件")
+	flag.StringVar(&cfPublisher.ZoneID, "cfzone", "", "Cloudflare 区域 ID")
+	flag.StringVar(&cfRecords, "cfrecord", "", "Cloudflare 解析记录")
+	flag.StringVar(&cfType, "cftype", "A", "Cloudflare 记录类型")
+	flag.StringVar(&cfPublisher.Token, "cftoken", os.Getenv("CF_API_TOKEN"), "Cloudflare API 令牌")
+	flag.StringVar(&cfPublisher.Key, "cfkey", os.Getenv("CF_API_KEY"), "Cloudflare API 密钥")
+	flag.StringVar(&cfPublisher.Email, "cfemail", os.Getenv("CF_API_EMAIL"), "Cloudflare 账户邮箱")
+	flag.IntVar(&cfTTL, "cfttl", 1, "Cloudflare 记录 TTL")
+	flag.BoolVar(&cfProxied, "cfproxied", false, "Cloudflare 代理状态")
+	flag.StringVar(&dpPublisher.Domain, "dpdomain", "", "DNSPod 域名")
+	flag.StringVar(&dpRecords, "dprecord", "", "DNSPod 主机记录")
+	flag.StringVar(&dpType, "dptype", "A", "DNSPod 记录类型")
+	flag.StringVar(&dpPublisher.Token, "dptoken", os.Getenv("DNSPOD_TOKEN"), "DNSPod API Token")
+	flag.IntVar(&dpTTL, "dpttl", 0, "DNSPod 记录 TTL")
+	flag.StringVar(&hostsDomain, "hosts", "", "更新 hosts 文件")
+	flag.StringVar(&hostsPub.Path, "hostsfile", "", "hosts 文件路径")
+	flag.BoolVar(&dryRun, "dryrun", false, "模拟更新解析记录")
+	flag.DurationVar(&interval, "interval", 0, "守护模式测速间隔")
+	flag.Float64Var(&threshold, "threshold", 20, "守护模式劣化阈值")
+	flag.StringVar(&hookCmd, "hook", "", "测速结果更新后执行的命令")
+	flag.StringVar(&listen, "listen", "", "API 服务监听地址")
+	flag.StringVar(&historyFile, "history", "", "测速历史记录文件")
+	flag.BoolVar(&stable, "stable", false, "按稳定性排序")
+	flag.IntVar(&stableRuns, "stableruns", 10, "稳定性评分参考的历史测速次数")
 
-	flag.BoolVar(&task.Disable, "dd", false, "禁用下载测速")
-	flag.BoolVar(&task.TestAll, "allip", false, "测速全部 IP")
+	flag.BoolVar(&cfg.Debug, "debug", false, "调试输出模式")
 
-	flag.BoolVar(&utils.Debug, "debug", false, "调试输出模式")
+	flag.StringVar(&configFile, "config", "", "配置文件")
+	flag.StringVar(&profile, "profile", "", "配置档")
 
 	flag.BoolVar(&printVersion, "v", false, "打印程序版本")
 	flag.Usage = func() { fmt.Print(help) }
 	flag.Parse()
 
-	if task.MinSpeed > 0 && time.Duration(maxDelay)*time.Millisecond == utils.InputMaxDelay {
+	if configFile != "" { // 读取配置文件（命令行参数优先）
+		values, err := config.Load(configFile, profile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = config.Apply(flag.CommandLine, values, configRules); err != nil {
+			log.Fatalf("配置文件[%s]有误：\n%v", configFile, err)
+		}
+	}
+
+	if streamOutput == "-" { // 标准输出只输出 NDJSON，其余输出改为标准错误
+		streamStdout = utils.RedirectStdout()
+	}
+	if cfg.Httping && cfg.TLSPing {
+		log.Fatal("参数 [-httping] 与 [-tlsping] 不能同时使用")
+	}
+	if cfg.MinSpeed > 0 && time.Duration(maxDelay)*time.Millisecond == utils.DefaultMaxDelay {
 		utils.Yellow.Println("[提示] 在使用 [-sl] 参数时，建议搭配 [-tl] 参数，以避免因凑不够 [-dn] 数量而一直测速...")
 	}
-	utils.InputMaxDelay = time.Duration(maxDelay) * time.Millisecond
-	utils.InputMinDelay = time.Duration(minDelay) * time.Millisecond
-	utils.InputMaxLossRate = float32(maxLossRate)
-	task.Timeout = time.Duration(downloadTime) * time.Second
-	task.HttpingCFColomap = task.MapColoMap()
+	cfg.MaxDelay = time.Duration(maxDelay) * time.Millisecond
+	cfg.MinDelay = time.Duration(minDelay) * time.Millisecond
+	cfg.MaxLossRate = float32(maxLossRate)
+	cfg.MaxJitter = time.Duration(maxJitter) * time.Millisecond
+	order, err := utils.ParseSortOrder(sortExpr)
+	if err != nil {
+		log.Fatalf("参数 [-sort] 有误：%v", err)
+	}
+	cfg.Sort = order
+	if cfg.Protocol, err = task.ParseProtocol(protocol); err != nil {
+		log.Fatalf("参数 [-proto] 有误：%v", err)
+	}
+	if cfg.HttpingStatusCodes, err = task.ParseStatusCodes(httpingCode); err != nil {
+		log.Fatalf("参数 [-httping-code] 有误：%v", err)
+	}
+	if cfg.DownloadStatusCodes, err = task.ParseStatusCodes(downloadCode); err != nil {
+		log.Fatalf("参数 [-dcode] 有误：%v", err)
+	}
+	if httpingRegexp != "" {
+		if cfg.HttpingCheck.Regexp, err = regexp.Compile(httpingRegexp); err != nil {
+			log.Fatalf("参数 [-httping-regexp] 有误：%v", err)
+		}
+	}
+	if httpingSHA256 != "" {
+		if cfg.HttpingCheck.SHA256, err = task.ParseSHA256(httpingSHA256); err != nil {
+			log.Fatalf("参数 [-httping-sha256] 有误：%v", err)
+		}
+	}
+	if strings.HasPrefix(body, "@") { // 从文件读取请求体
+		content, err := os.ReadFile(body[1:])
+		if err != nil {
+			log.Fatalf("读取请求体文件[%s]失败：%v", body[1:], err)
+		}
+		body = string(content)
+	}
+	cfg.Body = body
+	if cfg.HttpingCheck.Enabled() && !cfg.Httping {
+		utils.Yellow.Println("[提示] 响应内容校验参数 [-httping-*] 仅在 HTTPing 模式 [-httping] 下有效...")
+	}
+	if (cfg.Protocol == task.ProtocolHTTP2 || cfg.Protocol == task.ProtocolHTTP3) && !strings.HasPrefix(strings.ToLower(cfg.URL), "https://") {
+		log.Fatalf("参数 [-proto %s] 需要使用 HTTPS 测速地址 [-url]", cfg.Protocol)
+	}
+	cfg.Timeout = time.Duration(downloadTime) * time.Second
+	cfg.UploadSize = int64(uploadSize) * 1024 * 1024
+	if stable && historyFile == "" { // 稳定性评分需要记录测速历史
+		historyFile = history.DefaultPath
+	}
 
 	if printVersion {
 		println(version)
@@ -136,18 +480,219 @@ func main() {
 
 	fmt.Printf("# XIU2/CloudflareSpeedTest %s \n\n", version)
 
-	// 开始延迟测速 + 过滤延迟/丢包
-	pingData := task.NewPing().Run().FilterDelay().FilterLossRate()
-	// 开始下载测速
-	speedData := task.TestDownloadSpeed(pingData)
-	utils.ExportCsv(speedData) // 输出文件
-	speedData.Print()          // 打印结果
-	endPrint()                 // 根据情况选择退出方式（针对 Windows）
+	ctx := interruptContext() // 按下 Ctrl+C 时中止测速，并输出已获取的结果
+	scanner := task.NewScanner(cfg)
+	if streamOutput != "" { // 实时输出延迟测速结果
+		w := openStream(streamOutput)
+		defer w.Close()
+		scanner.SetSink(utils.NewNDJSONSink(w))
+	}
+	meta := exportMeta(scanner.Config())
+	if err := utils.ValidateExport(output, outputFormat, meta); err != nil {
+		log.Fatal(err)
+	}
+	publishers := buildPublishers()
+
+	scan := scanner.Run
+	if stable { // 结合历史记录按稳定性排序
+		scan = func(ctx context.Context) (utils.DownloadSpeedSet, error) {
+			data, err := scanner.Run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return rankStable(data, scanner.Config().DisableDownload), nil
+		}
+	}
+	var daemon *task.Daemon
+	if interval > 0 { // 守护模式：定时重复测速，仅在最优 IP 变化或劣化时更新结果
+		daemon = task.NewDaemon(scanner, interval, threshold, func(ctx context.Context, start time.Time, data utils.DownloadSpeedSet, reason string) {
+			meta := meta // 各轮结果使用独立的元数据，避免与 API 触发的测速同时修改
+			meta.StartTime, meta.EndTime = start, time.Now()
+			handleResult(ctx, meta, publishers, data, reason)
+		})
+	}
+	if listen != "" { // API 服务：通过 HTTP 触发测速、查询进度及最新结果
+		progress := &utils.Progress{}
+		scanner.SetProgress(progress)
+		srv := server.New(ctx, scan, meta, progress)
+		srv.Stats = scanner.Stats
+		srv.OnResult = func(ctx context.Context, meta utils.ExportMeta, data utils.DownloadSpeedSet) {
+			if daemon != nil { // 守护模式下同样仅在最优 IP 变化或劣化时更新结果
+				daemon.Handle(ctx, meta.StartTime, data)
+				return
+			}
+			handleResult(ctx, meta, publishers, data, "API")
+		}
+		go func() {
+			if err := srv.ListenAndServe(ctx, listen); err != nil {
+				log.Fatalf("API 服务启动失败：%v", err)
+			}
+		}()
+		utils.Cyan.Printf("[信息] API 服务已启动，监听地址：%s\n\n", listen)
+		scan = srv.Scan
+	}
+
+	if daemon != nil {
+		daemon.Scan = scan
+		daemon.Run(ctx)
+		endPrint()
+		return
+	}
+
+	// 开始延迟测速 + 过滤延迟/丢包 + 下载测速
+	speedData, err := scan(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	meta.EndTime = time.Now()
+	handleResult(ctx, meta, publishers, speedData, "")
+	if listen != "" && ctx.Err() == nil { // 保持 API 服务运行，直到按下 Ctrl+C
+		fmt.Println("\n[信息] API 服务运行中，可通过 POST /scan 再次测速（按下 Ctrl+C 退出）")
+		<-ctx.Done()
+	}
+	endPrint() // 根据情况选择退出方式（针对 Windows）
+}
+
+// 同一时间只处理一份测速结果（API 触发的测速可能与首次测速、守护模式同时完成）
+var resultMu sync.Mutex
+
+// 处理测速结果：输出文件、记录历史、打印结果、发布测速结果、执行钩子命令
+func handleResult(ctx context.Context, meta utils.ExportMeta, publishers []publish.Publisher, data utils.DownloadSpeedSet, reason string) {
+	resultMu.Lock()
+	defer resultMu.Unlock()
+	if err := utils.Export(output, outputFormat, meta, data); err != nil { // 输出文件，失败时继续运行（守护模式下一轮再试）
+		utils.Red.Printf("[错误] %v\n", err)
+	}
+	recordHistory(ctx, meta.EndTime, data) // 记录测速历史
+	data.Print(printNum, output)           // 打印结果
+	publishResult(ctx, publishers, data)   // 发布测速结果（更新解析记录等）
+	runHook(ctx, data, reason)             // 执行钩子命令
+}
+
+// 根据参数创建发布器（更新解析记录、hosts 文件等）
+func buildPublishers() []publish.Publisher {
+	var publishers []publish.Publisher
+	if cfPublisher.ZoneID != "" && cfRecords != "" {
+		for _, name := range splitList(cfRecords) {
+			cfPublisher.Records = append(cfPublisher.Records, publish.CloudflareRecord{Name: name, Type: cfType, TTL: cfTTL, Proxied: cfProxied})
+		}
+		cfPublisher.DryRun = dryRun
+		publishers = append(publishers, &cfPublisher)
+	}
+	if dpPublisher.Domain != "" && dpRecords != "" {
+		for _, sub := range splitList(dpRecords) {
+			dpPublisher.Records = append(dpPublisher.Records, publish.DNSPodRecord{SubDomain: sub, Type: dpType, TTL: dpTTL})
+		}
+		dpPublisher.DryRun = dryRun
+		publishers = append(publishers, &dpPublisher)
+	}
+	if hostsDomain != "" {
+		hostsPub.Domains = splitList(hostsDomain)
+		hostsPub.DryRun = dryRun
+		publishers = append(publishers, &hostsPub)
+	}
+	return publishers
+}
+
+// 发布测速结果（更新解析记录等）
+func publishResult(ctx context.Context, publishers []publish.Publisher, data utils.DownloadSpeedSet) {
+	if len(publishers) == 0 {
+		return
+	}
+	if ctx.Err() != nil { // 测速被中断时不发布不完整的结果
+		utils.Yellow.Println("[信息] 测速已中断，跳过发布测速结果。")
+		return
+	}
+	fmt.Println()
+	_ = publish.Run(ctx, publishers, data)
+}
+
+// 执行钩子命令，通过环境变量传递最优 IP 等信息
+func runHook(ctx context.Context, data utils.DownloadSpeedSet, reason string) {
+	if hookCmd == "" || ctx.Err() != nil || len(data) == 0 {
+		return
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hookCmd)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hookCmd)
+	}
+	cmd.Env = append(os.Environ(),
+		"CFST_BEST_IP="+data[0].IP.String(),
+		"CFST_IPS="+strings.Join(publish.TopIPs(data, 0), ","),
+		"CFST_OUTPUT="+output,
+		"CFST_REASON="+reason,
+	)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	fmt.Println()
+	if err := cmd.Run(); err != nil {
+		utils.Red.Printf("[错误] 钩子命令执行失败：%v\n", err)
+	}
+}
+
+// headerFlag 可重复指定的 -H 参数，每次添加一个或多个请求头
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	return ""
+}
+
+func (h headerFlag) Set(value string) error {
+	return task.ParseHeaders(http.Header(h), value)
+}
+
+// 按英文逗号分隔参数值，并去除空白及空项
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// 打开实时输出的目标，- 表示标准输出
+func openStream(path string) io.WriteCloser {
+	if path == "-" {
+		return streamStdout
+	}
+	fp, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("创建文件[%s]失败：%v", path, err)
+	}
+	return fp
+}
+
+// 生成结果文件的元数据
+func exportMeta(c task.Config) utils.ExportMeta {
+	return utils.ExportMeta{
+		Version:   version,
+		Mode:      c.Mode(),
+		Port:      c.TCPPort,
+		URL:       c.URL,
+		StartTime: time.Now(),
+		Domains:   splitList(domains),
+		Top:       topNum,
+	}
+}
+
+// 创建在收到 Ctrl+C (SIGINT/SIGTERM) 时取消的上下文
+// 第一次中断时停止测速并输出已获取的结果，再次中断则直接退出
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop() // 恢复默认的信号处理，再次按下 Ctrl+C 时直接退出
+		utils.Yellow.Println("\n[信息] 测速已中断，正在输出已获取的测速结果... (再次按下 Ctrl+C 直接退出)")
+	}()
+	return ctx
 }
 
 // 根据情况选择退出方式（针对 Windows）
 func endPrint() {
-	if utils.NoPrintResult() { // 如果不需要打印测速结果，则直接退出
+	if printNum == 0 { // 如果不需要打印测速结果，则直接退出
 		return
 	}
 	if runtime.GOOS == "windows" { // 如果是 Windows 系统，则需要按下 回车键 或 Ctrl+C 退出（避免通过双击运行时，测速完毕后直接关闭）
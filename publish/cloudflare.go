@@ -0,0 +1,196 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+const defaultCloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// CloudflareRecord 需要更新的 Cloudflare 解析记录
+type CloudflareRecord struct {
+	Name    string // 记录名称（完整域名），如 cdn.example.com
+	Type    string // 记录类型：A / AAAA（默认 A）
+	ID      string // 记录 ID（为空时按名称和类型查找，找不到则新建）
+	TTL     int    // TTL，1 为自动（默认 1）
+	Proxied bool   // 是否开启 Cloudflare 代理
+}
+
+// Cloudflare 通过 Cloudflare v4 API 更新 DNS 解析记录
+// 多个记录会按顺序分配到前 N 个 IP（A 记录使用 IPv4，AAAA 记录使用 IPv6）
+type Cloudflare struct {
+	APIURL  string             // API 地址（默认 https://api.cloudflare.com/client/v4）
+	Token   string             // API 令牌（与 Key+Email 二选一）
+	Key     string             // 全局 API 密钥
+	Email   string             // 账户邮箱（使用 API 密钥时必填）
+	ZoneID  string             // 区域 ID
+	Records []CloudflareRecord // 需要更新的解析记录
+	Client  *http.Client       // HTTP 客户端（默认 10 秒超时）
+	DryRun  bool               // 仅输出将要进行的修改，不实际更新
+}
+
+// cloudflareDNSRecord Cloudflare API 中的 DNS 记录
+type cloudflareDNSRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+// cloudflareResponse Cloudflare API 通用响应
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// Name 返回发布器名称
+func (c *Cloudflare) Name() string {
+	return "Cloudflare DNS"
+}
+
+// Publish 将 IP 按顺序写入各解析记录
+func (c *Cloudflare) Publish(ctx context.Context, ips []string) error {
+	if c.ZoneID == "" {
+		return fmt.Errorf("缺少区域 ID")
+	}
+	if c.Token == "" && (c.Key == "" || c.Email == "") {
+		return fmt.Errorf("缺少 API 令牌或 API 密钥+邮箱")
+	}
+	allocator := newIPAllocator(ips)
+	existing := make(map[string][]cloudflareDNSRecord) // 按 名称+类型 缓存的已有记录
+	occurrence := make(map[string]int)                 // 相同 名称+类型 的记录出现次数
+	for _, r := range c.Records {
+		recordType := normalizeType(r.Type)
+		ip, ok := allocator.next(recordType)
+		if !ok {
+			utils.Yellow.Printf("[信息] 可用的 %s 记录 IP 数量不足，跳过解析记录 %s\n", recordType, r.Name)
+			continue
+		}
+		record := cloudflareDNSRecord{
+			ID:      r.ID,
+			Type:    recordType,
+			Name:    r.Name,
+			Content: ip,
+			TTL:     r.TTL,
+			Proxied: r.Proxied,
+		}
+		if record.TTL <= 0 {
+			record.TTL = 1
+		}
+
+		// 未指定记录 ID 时，按名称和类型查找已有记录
+		if record.ID == "" {
+			key := recordType + " " + r.Name
+			if _, ok := existing[key]; !ok {
+				records, err := c.listRecords(ctx, r.Name, recordType)
+				if err != nil {
+					return err
+				}
+				existing[key] = records
+			}
+			if n := occurrence[key]; n < len(existing[key]) {
+				record.ID = existing[key][n].ID
+			}
+			occurrence[key]++
+		}
+
+		if c.DryRun {
+			printDryRun(c.Name(), r.Name, recordType, record.ID, record.Content)
+			continue
+		}
+		if err := c.putRecord(ctx, record); err != nil {
+			return fmt.Errorf("更新解析记录 %s (%s) 失败：%w", r.Name, recordType, err)
+		}
+		utils.Green.Printf("[信息] Cloudflare 解析记录 %s (%s) 已更新为 %s\n", r.Name, recordType, record.Content)
+	}
+	return nil
+}
+
+// listRecords 查找指定名称和类型的解析记录
+func (c *Cloudflare) listRecords(ctx context.Context, name, recordType string) ([]cloudflareDNSRecord, error) {
+	query := url.Values{"name": {name}, "type": {recordType}}
+	var records []cloudflareDNSRecord
+	if err := c.do(ctx, http.MethodGet, "/zones/"+c.ZoneID+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return nil, fmt.Errorf("查找解析记录 %s (%s) 失败：%w", name, recordType, err)
+	}
+	return records, nil
+}
+
+// putRecord 更新（有 ID 时）或新建解析记录
+func (c *Cloudflare) putRecord(ctx context.Context, record cloudflareDNSRecord) error {
+	path := "/zones/" + c.ZoneID + "/dns_records"
+	method := http.MethodPost
+	if record.ID != "" {
+		path += "/" + record.ID
+		method = http.MethodPut
+	}
+	return c.do(ctx, method, path, record, nil)
+}
+
+// do 发送 API 请求并解析响应，result 为空时忽略响应数据
+func (c *Cloudflare) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = defaultCloudflareAPI
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(apiURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" { // API 令牌方式（自定义权限）
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else { // API 密钥方式（全局权限）
+		req.Header.Set("X-Auth-Email", c.Email)
+		req.Header.Set("X-Auth-Key", c.Key)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var res cloudflareResponse
+	if err = json.NewDecoder(response.Body).Decode(&res); err != nil {
+		return fmt.Errorf("解析 API 响应失败（HTTP 状态码 %d）：%w", response.StatusCode, err)
+	}
+	if !res.Success {
+		msgs := make([]string, 0, len(res.Errors))
+		for _, e := range res.Errors {
+			msgs = append(msgs, fmt.Sprintf("[%d] %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("API 返回错误（HTTP 状态码 %d）：%s", response.StatusCode, strings.Join(msgs, "; "))
+	}
+	if result != nil {
+		return json.Unmarshal(res.Result, result)
+	}
+	return nil
+}
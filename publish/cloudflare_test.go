@@ -0,0 +1,157 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCloudflare 本地模拟的 Cloudflare v4 API
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	records []cloudflareDNSRecord // 已有解析记录
+	puts    map[string]string     // 记录 ID -> 更新后的 IP
+	posts   []cloudflareDNSRecord // 新建的记录
+	auth    string                // 最近一次请求的 Authorization
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = r.Header.Get("Authorization")
+	if f.auth != "Bearer test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"Authentication error"}],"result":null}`))
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/zones/zone1/dns_records") {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":7003,"message":"Could not route"}],"result":null}`))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		var result []cloudflareDNSRecord
+		for _, rec := range f.records {
+			if rec.Name == r.URL.Query().Get("name") && rec.Type == r.URL.Query().Get("type") {
+				result = append(result, rec)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "result": result})
+	case http.MethodPut:
+		var rec cloudflareDNSRecord
+		_ = json.NewDecoder(r.Body).Decode(&rec)
+		f.puts[strings.TrimPrefix(r.URL.Path, "/zones/zone1/dns_records/")] = rec.Content
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "result": rec})
+	case http.MethodPost:
+		var rec cloudflareDNSRecord
+		_ = json.NewDecoder(r.Body).Decode(&rec)
+		f.posts = append(f.posts, rec)
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "result": rec})
+	}
+}
+
+// TestCloudflare_Publish 测试多个记录按顺序分配前 N 个 IP
+func TestCloudflare_Publish(t *testing.T) {
+	api := &fakeCloudflare{
+		records: []cloudflareDNSRecord{
+			{ID: "id1", Type: "A", Name: "cdn.example.com"},
+			{ID: "id2", Type: "A", Name: "cdn.example.com"},
+		},
+		puts: map[string]string{},
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	cf := &Cloudflare{
+		APIURL: server.URL,
+		Token:  "test-token",
+		ZoneID: "zone1",
+		Records: []CloudflareRecord{
+			{Name: "cdn.example.com"},
+			{Name: "cdn.example.com"},
+			{Name: "new.example.com"},
+			{Name: "v6.example.com", Type: "AAAA"},
+			{ID: "fixed", Name: "fixed.example.com"},
+		},
+	}
+	ips := []string{"1.1.1.1", "2606:4700::1", "2.2.2.2", "3.3.3.3", "4.4.4.4"}
+
+	if err := cf.Publish(context.Background(), ips); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	wantPuts := map[string]string{"id1": "1.1.1.1", "id2": "2.2.2.2", "fixed": "4.4.4.4"}
+	for id, ip := range wantPuts {
+		if api.puts[id] != ip {
+			t.Errorf("record %s = %q, expected %s", id, api.puts[id], ip)
+		}
+	}
+	if len(api.posts) != 2 {
+		t.Fatalf("expected 2 created records, got %d", len(api.posts))
+	}
+	if api.posts[0].Name != "new.example.com" || api.posts[0].Content != "3.3.3.3" || api.posts[0].TTL != 1 {
+		t.Errorf("unexpected created record: %+v", api.posts[0])
+	}
+	if api.posts[1].Type != "AAAA" || api.posts[1].Content != "2606:4700::1" {
+		t.Errorf("unexpected created AAAA record: %+v", api.posts[1])
+	}
+}
+
+// TestCloudflare_Publish_APIError 测试 API 返回错误时报告错误信息
+func TestCloudflare_Publish_APIError(t *testing.T) {
+	server := httptest.NewServer(&fakeCloudflare{puts: map[string]string{}})
+	defer server.Close()
+
+	cf := &Cloudflare{
+		APIURL:  server.URL,
+		Token:   "wrong-token",
+		ZoneID:  "zone1",
+		Records: []CloudflareRecord{{ID: "id1", Name: "cdn.example.com"}},
+	}
+
+	err := cf.Publish(context.Background(), []string{"1.1.1.1"})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "Authentication error") {
+		t.Errorf("error %q does not contain API message", err)
+	}
+}
+
+// TestCloudflare_Publish_MissingCredentials 测试缺少凭据时直接返回错误
+func TestCloudflare_Publish_MissingCredentials(t *testing.T) {
+	cf := &Cloudflare{ZoneID: "zone1", Key: "key-only"}
+	if err := cf.Publish(context.Background(), []string{"1.1.1.1"}); err == nil {
+		t.Error("expected error for missing email, got nil")
+	}
+}
+
+// TestCloudflare_Publish_DryRun 测试模拟运行时不修改记录
+func TestCloudflare_Publish_DryRun(t *testing.T) {
+	api := &fakeCloudflare{
+		records: []cloudflareDNSRecord{{ID: "id1", Type: "A", Name: "cdn.example.com"}},
+		puts:    map[string]string{},
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	cf := &Cloudflare{
+		APIURL:  server.URL,
+		Token:   "test-token",
+		ZoneID:  "zone1",
+		Records: []CloudflareRecord{{Name: "cdn.example.com"}, {Name: "new.example.com"}},
+		DryRun:  true,
+	}
+
+	if err := cf.Publish(context.Background(), []string{"1.1.1.1", "2.2.2.2"}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if len(api.puts) != 0 || len(api.posts) != 0 {
+		t.Errorf("expected no modifications in dry run, got puts=%v posts=%v", api.puts, api.posts)
+	}
+}
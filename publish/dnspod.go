@@ -0,0 +1,197 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+const (
+	defaultDNSPodAPI  = "https://dnsapi.cn"
+	defaultDNSPodLine = "默认"
+)
+
+// DNSPodRecord 需要更新的 DNSPod 解析记录
+type DNSPodRecord struct {
+	SubDomain string // 主机记录，如 www、@
+	Type      string // 记录类型：A / AAAA（默认 A）
+	ID        string // 记录 ID（为空时按主机记录和类型查找，找不到则新建）
+	Line      string // 记录线路（默认 "默认"）
+	TTL       int    // TTL（为 0 时使用 DNSPod 默认值）
+}
+
+// DNSPod 通过 DNSPod API 更新解析记录
+// 多个记录会按顺序分配到前 N 个 IP（A 记录使用 IPv4，AAAA 记录使用 IPv6）
+type DNSPod struct {
+	APIURL  string         // API 地址（默认 https://dnsapi.cn）
+	Token   string         // API Token，格式为 ID,Token
+	Domain  string         // 域名，如 example.com
+	Records []DNSPodRecord // 需要更新的解析记录
+	Client  *http.Client   // HTTP 客户端（默认 10 秒超时）
+	DryRun  bool           // 仅输出将要进行的修改，不实际更新
+}
+
+// dnspodStatus DNSPod API 响应状态，code 为 "1" 表示成功
+type dnspodStatus struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// dnspodListResponse Record.List 的响应
+type dnspodListResponse struct {
+	Status  dnspodStatus `json:"status"`
+	Records []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"records"`
+}
+
+// Name 返回发布器名称
+func (d *DNSPod) Name() string {
+	return "DNSPod"
+}
+
+// Publish 将 IP 按顺序写入各解析记录
+func (d *DNSPod) Publish(ctx context.Context, ips []string) error {
+	if d.Domain == "" {
+		return fmt.Errorf("缺少域名")
+	}
+	if d.Token == "" {
+		return fmt.Errorf("缺少 API Token")
+	}
+	allocator := newIPAllocator(ips)
+	existing := make(map[string][]string) // 按 主机记录+类型 缓存的已有记录 ID
+	occurrence := make(map[string]int)    // 相同 主机记录+类型 的记录出现次数
+	for _, r := range d.Records {
+		recordType := normalizeType(r.Type)
+		fullName := r.SubDomain + "." + d.Domain
+		if r.SubDomain == "@" {
+			fullName = d.Domain
+		}
+		ip, ok := allocator.next(recordType)
+		if !ok {
+			utils.Yellow.Printf("[信息] 可用的 %s 记录 IP 数量不足，跳过解析记录 %s\n", recordType, fullName)
+			continue
+		}
+
+		// 未指定记录 ID 时，按主机记录和类型查找已有记录
+		id := r.ID
+		if id == "" {
+			key := recordType + " " + r.SubDomain
+			if _, ok := existing[key]; !ok {
+				ids, err := d.listRecords(ctx, r.SubDomain, recordType)
+				if err != nil {
+					return err
+				}
+				existing[key] = ids
+			}
+			if n := occurrence[key]; n < len(existing[key]) {
+				id = existing[key][n]
+			}
+			occurrence[key]++
+		}
+
+		if d.DryRun {
+			printDryRun(d.Name(), fullName, recordType, id, ip)
+			continue
+		}
+		params := url.Values{
+			"sub_domain":  {r.SubDomain},
+			"record_type": {recordType},
+			"record_line": {r.Line},
+			"value":       {ip},
+		}
+		if r.Line == "" {
+			params.Set("record_line", defaultDNSPodLine)
+		}
+		if r.TTL > 0 {
+			params.Set("ttl", strconv.Itoa(r.TTL))
+		}
+		action := "Record.Create"
+		if id != "" {
+			action = "Record.Modify"
+			params.Set("record_id", id)
+		}
+		var status struct {
+			Status dnspodStatus `json:"status"`
+		}
+		if err := d.do(ctx, action, params, &status); err != nil {
+			return fmt.Errorf("更新解析记录 %s (%s) 失败：%w", fullName, recordType, err)
+		}
+		if err := status.Status.err(); err != nil {
+			return fmt.Errorf("更新解析记录 %s (%s) 失败：%w", fullName, recordType, err)
+		}
+		utils.Green.Printf("[信息] DNSPod 解析记录 %s (%s) 已更新为 %s\n", fullName, recordType, ip)
+	}
+	return nil
+}
+
+// listRecords 查找指定主机记录和类型的解析记录 ID
+func (d *DNSPod) listRecords(ctx context.Context, subDomain, recordType string) ([]string, error) {
+	params := url.Values{"sub_domain": {subDomain}, "record_type": {recordType}}
+	var res dnspodListResponse
+	if err := d.do(ctx, "Record.List", params, &res); err != nil {
+		return nil, fmt.Errorf("查找解析记录 %s (%s) 失败：%w", subDomain, recordType, err)
+	}
+	if res.Status.Code == "10" { // 没有记录
+		return nil, nil
+	}
+	if err := res.Status.err(); err != nil {
+		return nil, fmt.Errorf("查找解析记录 %s (%s) 失败：%w", subDomain, recordType, err)
+	}
+	ids := make([]string, 0, len(res.Records))
+	for _, rec := range res.Records {
+		if rec.Name == subDomain && rec.Type == recordType {
+			ids = append(ids, rec.ID)
+		}
+	}
+	return ids, nil
+}
+
+// do 发送 API 请求并解析 JSON 响应
+func (d *DNSPod) do(ctx context.Context, action string, params url.Values, result any) error {
+	params.Set("login_token", d.Token)
+	params.Set("format", "json")
+	params.Set("domain", d.Domain)
+	apiURL := d.APIURL
+	if apiURL == "" {
+		apiURL = defaultDNSPodAPI
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(apiURL, "/")+"/"+action, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "CloudflareSpeedTest")
+
+	client := d.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err = json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("解析 API 响应失败（HTTP 状态码 %d）：%w", response.StatusCode, err)
+	}
+	return nil
+}
+
+// err 将非成功的响应状态转换为错误
+func (s dnspodStatus) err() error {
+	if s.Code == "1" {
+		return nil
+	}
+	return fmt.Errorf("API 返回错误（状态码 %s）：%s", s.Code, s.Message)
+}
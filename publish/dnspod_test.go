@@ -0,0 +1,119 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDNSPod 本地模拟的 DNSPod API
+type fakeDNSPod struct {
+	mu      sync.Mutex
+	records map[string]string // 主机记录 -> 记录 ID（仅 A 记录）
+	actions []string          // 收到的修改操作：动作 主机记录 IP [记录 ID]
+}
+
+func (f *fakeDNSPod) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = r.ParseForm()
+	if r.Form.Get("login_token") != "123,token" {
+		_, _ = w.Write([]byte(`{"status":{"code":"-1","message":"Login fail"}}`))
+		return
+	}
+	action := strings.TrimPrefix(r.URL.Path, "/")
+	sub := r.Form.Get("sub_domain")
+	switch action {
+	case "Record.List":
+		id, ok := f.records[sub]
+		if !ok || r.Form.Get("record_type") != "A" {
+			_, _ = w.Write([]byte(`{"status":{"code":"10","message":"No records"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":  map[string]string{"code": "1", "message": "Action completed successful"},
+			"records": []map[string]string{{"id": id, "name": sub, "type": "A", "value": "0.0.0.0"}},
+		})
+	case "Record.Modify", "Record.Create":
+		f.actions = append(f.actions, strings.TrimSpace(action+" "+sub+" "+r.Form.Get("value")+" "+r.Form.Get("record_id")))
+		_, _ = w.Write([]byte(`{"status":{"code":"1","message":"Action completed successful"}}`))
+	}
+}
+
+// TestDNSPod_Publish 测试更新已有记录与新建记录
+func TestDNSPod_Publish(t *testing.T) {
+	api := &fakeDNSPod{records: map[string]string{"www": "42"}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	dp := &DNSPod{
+		APIURL: server.URL,
+		Token:  "123,token",
+		Domain: "example.com",
+		Records: []DNSPodRecord{
+			{SubDomain: "www"},
+			{SubDomain: "cdn"},
+			{SubDomain: "v6", Type: "AAAA"},
+		},
+	}
+
+	if err := dp.Publish(context.Background(), []string{"1.1.1.1", "2606:4700::1", "2.2.2.2"}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	want := []string{
+		"Record.Modify www 1.1.1.1 42",
+		"Record.Create cdn 2.2.2.2",
+		"Record.Create v6 2606:4700::1",
+	}
+	if strings.Join(api.actions, "|") != strings.Join(want, "|") {
+		t.Errorf("actions = %v, expected %v", api.actions, want)
+	}
+}
+
+// TestDNSPod_Publish_DryRun 测试模拟运行时不修改记录
+func TestDNSPod_Publish_DryRun(t *testing.T) {
+	api := &fakeDNSPod{records: map[string]string{"www": "42"}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	dp := &DNSPod{
+		APIURL:  server.URL,
+		Token:   "123,token",
+		Domain:  "example.com",
+		Records: []DNSPodRecord{{SubDomain: "www"}, {SubDomain: "cdn"}},
+		DryRun:  true,
+	}
+
+	if err := dp.Publish(context.Background(), []string{"1.1.1.1", "2.2.2.2"}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if len(api.actions) != 0 {
+		t.Errorf("expected no modifications in dry run, got %v", api.actions)
+	}
+}
+
+// TestDNSPod_Publish_APIError 测试 API 返回错误时报告错误信息
+func TestDNSPod_Publish_APIError(t *testing.T) {
+	server := httptest.NewServer(&fakeDNSPod{})
+	defer server.Close()
+
+	dp := &DNSPod{
+		APIURL:  server.URL,
+		Token:   "123,wrong",
+		Domain:  "example.com",
+		Records: []DNSPodRecord{{SubDomain: "www"}},
+	}
+
+	err := dp.Publish(context.Background(), []string{"1.1.1.1"})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "Login fail") || !strings.Contains(err.Error(), "-1") {
+		t.Errorf("error %q does not contain API status", err)
+	}
+}
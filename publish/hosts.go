@@ -0,0 +1,166 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+const (
+	hostsBlockBegin = "# BEGIN CloudflareSpeedTest"
+	hostsBlockEnd   = "# END CloudflareSpeedTest"
+	hostsBackupExt  = ".cfst_backup"
+)
+
+// Hosts 将最优 IP 写入 hosts 文件中由 CFST 管理的区块（区块外的内容保持不变）
+// 写入前会备份原文件，写入失败时自动回滚
+type Hosts struct {
+	Path    string   // hosts 文件路径（默认为系统 hosts 文件）
+	Domains []string // 需要指向最优 IP 的域名
+	DryRun  bool     // 仅输出将要写入的内容，不实际修改
+}
+
+// Name 返回发布器名称
+func (h *Hosts) Name() string {
+	return "Hosts"
+}
+
+// defaultHostsPath 返回当前系统的 hosts 文件路径
+func defaultHostsPath() string {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+func (h *Hosts) path() string {
+	if h.Path == "" {
+		return defaultHostsPath()
+	}
+	return h.Path
+}
+
+// BackupPath 返回备份文件路径
+func (h *Hosts) BackupPath() string {
+	return h.path() + hostsBackupExt
+}
+
+// Publish 将所有域名指向最优 IP（ips[0]）
+func (h *Hosts) Publish(_ context.Context, ips []string) error {
+	if len(h.Domains) == 0 {
+		return fmt.Errorf("缺少域名")
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("没有可用的 IP")
+	}
+	path := h.path()
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("读取 hosts 文件[%s]失败：%w", path, err)
+	}
+	content := renderHosts(string(original), ips[0], h.Domains)
+	if h.DryRun {
+		utils.Cyan.Printf("[模拟] Hosts 将写入 %s 以下内容：\n%s\n", path, hostsBlock(ips[0], h.Domains, "\n"))
+		return nil
+	}
+	if content == string(original) {
+		utils.Green.Printf("[信息] Hosts 文件 %s 无需更新（已指向 %s）\n", path, ips[0])
+		return nil
+	}
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if original != nil { // 备份原文件
+		if err = os.WriteFile(h.BackupPath(), original, mode); err != nil {
+			return fmt.Errorf("备份 hosts 文件失败：%w", err)
+		}
+	}
+	if err = writeFileAtomic(path, []byte(content), mode); err != nil {
+		if original != nil { // 写入失败，回滚为原文件
+			if rerr := h.Rollback(); rerr != nil {
+				return fmt.Errorf("写入 hosts 文件失败：%v，回滚失败：%w", err, rerr)
+			}
+		}
+		return fmt.Errorf("写入 hosts 文件失败（已回滚）：%w", err)
+	}
+	utils.Green.Printf("[信息] Hosts 文件 %s 已更新为 %s（备份：%s）\n", path, ips[0], h.BackupPath())
+	return nil
+}
+
+// Rollback 使用备份文件恢复 hosts 文件
+func (h *Hosts) Rollback() error {
+	backup, err := os.ReadFile(h.BackupPath())
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(h.path()); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return writeFileAtomic(h.path(), backup, mode)
+}
+
+// hostsBlock 生成 CFST 管理的区块
+func hostsBlock(ip string, domains []string, newline string) string {
+	var b strings.Builder
+	b.WriteString(hostsBlockBegin + newline)
+	for _, domain := range domains {
+		b.WriteString(ip + " " + domain + newline)
+	}
+	b.WriteString(hostsBlockEnd)
+	return b.String()
+}
+
+// renderHosts 替换（或追加）hosts 内容中 CFST 管理的区块
+func renderHosts(content, ip string, domains []string) string {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") { // 保持原文件的换行符（Windows）
+		newline = "\r\n"
+	}
+	block := hostsBlock(ip, domains, newline)
+	begin := strings.Index(content, hostsBlockBegin)
+	end := strings.Index(content, hostsBlockEnd)
+	if begin >= 0 && end > begin {
+		return content[:begin] + block + content[end+len(hostsBlockEnd):]
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += newline
+	}
+	return content + block + newline
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再替换目标文件
+// 部分环境（如 Docker 挂载的 /etc/hosts）不允许替换，此时直接写入目标文件
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return os.WriteFile(path, data, mode)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, mode); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		return os.WriteFile(path, data, mode)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package publish
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeHosts(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readHosts(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestHosts_Publish_Append(t *testing.T) {
+	original := "127.0.0.1 localhost\n"
+	path := writeHosts(t, original)
+	h := &Hosts{Path: path, Domains: []string{"a.example.com", "b.example.com"}}
+	if err := h.Publish(context.Background(), []string{"1.1.1.1", "2.2.2.2"}); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	want := original + hostsBlockBegin + "\n1.1.1.1 a.example.com\n1.1.1.1 b.example.com\n" + hostsBlockEnd + "\n"
+	if got := readHosts(t, path); got != want {
+		t.Errorf("hosts = %q, want %q", got, want)
+	}
+	if got := readHosts(t, h.BackupPath()); got != original {
+		t.Errorf("backup = %q, want %q", got, original)
+	}
+}
+
+func TestHosts_Publish_ReplaceBlock(t *testing.T) {
+	original := "127.0.0.1 localhost\n" + hostsBlockBegin + "\n1.1.1.1 a.example.com\n" + hostsBlockEnd + "\n10.0.0.1 nas\n"
+	path := writeHosts(t, original)
+	h := &Hosts{Path: path, Domains: []string{"a.example.com"}}
+	if err := h.Publish(context.Background(), []string{"2.2.2.2"}); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	want := "127.0.0.1 localhost\n" + hostsBlockBegin + "\n2.2.2.2 a.example.com\n" + hostsBlockEnd + "\n10.0.0.1 nas\n"
+	if got := readHosts(t, path); got != want {
+		t.Errorf("hosts = %q, want %q", got, want)
+	}
+}
+
+func TestHosts_Publish_CRLF(t *testing.T) {
+	path := writeHosts(t, "127.0.0.1 localhost\r\n")
+	h := &Hosts{Path: path, Domains: []string{"a.example.com"}}
+	if err := h.Publish(context.Background(), []string{"1.1.1.1"}); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	got := readHosts(t, path)
+	if strings.Count(got, "\r\n") != strings.Count(got, "\n") {
+		t.Errorf("hosts = %q, want CRLF line endings only", got)
+	}
+}
+
+func TestHosts_Publish_DryRun(t *testing.T) {
+	original := "127.0.0.1 localhost\n"
+	path := writeHosts(t, original)
+	h := &Hosts{Path: path, Domains: []string{"a.example.com"}, DryRun: true}
+	if err := h.Publish(context.Background(), []string{"1.1.1.1"}); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	if got := readHosts(t, path); got != original {
+		t.Errorf("hosts = %q, want unchanged", got)
+	}
+	if _, err := os.Stat(h.BackupPath()); !os.IsNotExist(err) {
+		t.Errorf("backup should not exist in dry run, stat err = %v", err)
+	}
+}
+
+func TestHosts_Rollback(t *testing.T) {
+	original := "127.0.0.1 localhost\n"
+	path := writeHosts(t, original)
+	h := &Hosts{Path: path, Domains: []string{"a.example.com"}}
+	if err := h.Publish(context.Background(), []string{"1.1.1.1"}); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	if err := h.Rollback(); err != nil {
+		t.Fatalf("Rollback error: %v", err)
+	}
+	if got := readHosts(t, path); got != original {
+		t.Errorf("hosts = %q, want %q", got, original)
+	}
+}
+
+func TestHosts_Publish_Errors(t *testing.T) {
+	path := writeHosts(t, "")
+	if err := (&Hosts{Path: path}).Publish(context.Background(), []string{"1.1.1.1"}); err == nil {
+		t.Error("expected error for missing domains")
+	}
+	if err := (&Hosts{Path: path, Domains: []string{"a.example.com"}}).Publish(context.Background(), nil); err == nil {
+		t.Error("expected error for empty IP list")
+	}
+}
@@ -0,0 +1,108 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// Publisher 将测速结果（按优先级排序的 IP 列表）发布到外部服务
+type Publisher interface {
+	Name() string                                    // 名称，用于输出日志
+	Publish(ctx context.Context, ips []string) error // 发布 IP 列表，ips[0] 为最优 IP
+}
+
+// TopIPs 返回测速结果中前 n 个 IP（n <= 0 时返回全部）
+func TopIPs(data []utils.CloudflareIPData, n int) []string {
+	if n <= 0 || n > len(data) {
+		n = len(data)
+	}
+	ips := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ips = append(ips, data[i].IP.String())
+	}
+	return ips
+}
+
+// Run 依次执行所有发布器，单个发布器失败不影响其他发布器
+func Run(ctx context.Context, publishers []Publisher, data []utils.CloudflareIPData) (err error) {
+	if len(publishers) == 0 {
+		return nil
+	}
+	if len(data) == 0 {
+		utils.Yellow.Println("[信息] 完整测速结果 IP 数量为 0，跳过发布测速结果。")
+		return nil
+	}
+	ips := TopIPs(data, 0)
+	var failed []string
+	for _, p := range publishers {
+		if e := p.Publish(ctx, ips); e != nil {
+			utils.Red.Printf("[错误] %s 发布失败：%v\n", p.Name(), e)
+			failed = append(failed, p.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("发布失败：%s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// 判断是否为 IPv4 地址
+func isIPv4(ip string) bool {
+	return strings.Contains(ip, ".")
+}
+
+// filterIPs 按解析记录类型（A / AAAA）筛选 IP
+func filterIPs(ips []string, recordType string) []string {
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			continue
+		}
+		if (recordType == "AAAA") != isIPv4(ip) {
+			result = append(result, ip)
+		}
+	}
+	return result
+}
+
+// ipAllocator 按记录类型依次分配 IP（A 记录使用 IPv4，AAAA 记录使用 IPv6）
+type ipAllocator struct {
+	ips      []string
+	assigned map[string]int // 各记录类型已分配的 IP 数量
+}
+
+func newIPAllocator(ips []string) *ipAllocator {
+	return &ipAllocator{ips: ips, assigned: make(map[string]int)}
+}
+
+// next 返回指定记录类型的下一个 IP，IP 数量不足时返回 false
+func (a *ipAllocator) next(recordType string) (string, bool) {
+	candidates := filterIPs(a.ips, recordType)
+	if a.assigned[recordType] >= len(candidates) {
+		return "", false
+	}
+	ip := candidates[a.assigned[recordType]]
+	a.assigned[recordType]++
+	return ip, true
+}
+
+// normalizeType 规范化记录类型（默认 A）
+func normalizeType(t string) string {
+	if t = strings.ToUpper(strings.TrimSpace(t)); t == "" {
+		return "A"
+	}
+	return t
+}
+
+// printDryRun 输出模拟运行时将要进行的修改
+func printDryRun(publisher, name, recordType, id, ip string) {
+	if id == "" {
+		utils.Cyan.Printf("[模拟] %s 将新建解析记录 %s (%s) 为 %s\n", publisher, name, recordType, ip)
+	} else {
+		utils.Cyan.Printf("[模拟] %s 将更新解析记录 %s (%s, ID: %s) 为 %s\n", publisher, name, recordType, id, ip)
+	}
+}
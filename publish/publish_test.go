@@ -0,0 +1,82 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+func testData(ips ...string) []utils.CloudflareIPData {
+	data := make([]utils.CloudflareIPData, 0, len(ips))
+	for _, ip := range ips {
+		data = append(data, utils.CloudflareIPData{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP(ip)}}})
+	}
+	return data
+}
+
+// TestTopIPs 测试获取前 N 个 IP
+func TestTopIPs(t *testing.T) {
+	data := testData("1.1.1.1", "2.2.2.2", "3.3.3.3")
+
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{"前 2 个", 2, []string{"1.1.1.1", "2.2.2.2"}},
+		{"全部", 0, []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}},
+		{"超出数量", 10, []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TopIPs(data, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopIPs(%d) = %v, expected %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterIPs 测试按记录类型筛选 IP
+func TestFilterIPs(t *testing.T) {
+	ips := []string{"1.1.1.1", "2606:4700::1", "invalid", "2.2.2.2"}
+
+	if got := filterIPs(ips, "A"); !reflect.DeepEqual(got, []string{"1.1.1.1", "2.2.2.2"}) {
+		t.Errorf("filterIPs(A) = %v", got)
+	}
+	if got := filterIPs(ips, "AAAA"); !reflect.DeepEqual(got, []string{"2606:4700::1"}) {
+		t.Errorf("filterIPs(AAAA) = %v", got)
+	}
+}
+
+type stubPublisher struct {
+	name string
+	err  error
+	ips  []string
+}
+
+func (s *stubPublisher) Name() string { return s.name }
+
+func (s *stubPublisher) Publish(_ context.Context, ips []string) error {
+	s.ips = ips
+	return s.err
+}
+
+// TestRun 测试单个发布器失败不影响其他发布器
+func TestRun(t *testing.T) {
+	failed := &stubPublisher{name: "failed", err: errors.New("boom")}
+	ok := &stubPublisher{name: "ok"}
+
+	err := Run(context.Background(), []Publisher{failed, ok}, testData("1.1.1.1", "2.2.2.2"))
+
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+	if !reflect.DeepEqual(ok.ips, []string{"1.1.1.1", "2.2.2.2"}) {
+		t.Errorf("ok publisher got %v", ok.ips)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// 指标名称前缀
+const metricsPrefix = "cfst_"
+
+// metricsWriter 以 Prometheus 文本格式输出指标
+type metricsWriter struct {
+	w   io.Writer
+	err error
+}
+
+// family 输出指标的 HELP 与 TYPE 行
+func (m *metricsWriter) family(name, typ, help string) {
+	m.printf("# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, typ)
+}
+
+// sample 输出一个样本，labels 为 键, 值 交替排列
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	for i := 0; i+1 < len(labels); i += 2 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", labels[i], labels[i+1])
+	}
+	if b.Len() > 0 {
+		m.printf("%s%s{%s} %g\n", metricsPrefix, name, b.String(), value)
+	} else {
+		m.printf("%s%s %g\n", metricsPrefix, name, value)
+	}
+}
+
+// gauge 输出只有一个样本的 gauge 指标
+func (m *metricsWriter) gauge(name, help string, value float64) {
+	m.family(name, "gauge", help)
+	m.sample(name, value)
+}
+
+func (m *metricsWriter) printf(format string, args ...any) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
+
+// boolFloat 将布尔值转换为 0 / 1
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = s.writeMetrics(w)
+}
+
+// writeMetrics 输出测速进度、最近一次测速统计及最新测速结果中各 IP 的指标
+func (s *Server) writeMetrics(w io.Writer) error {
+	m := &metricsWriter{w: w}
+	state := s.progress.State()
+	m.gauge("scan_running", "Whether a scan is currently running.", boolFloat(state.Running))
+	m.family("scan_progress", "gauge", "Completed and total items of the current scan stage.")
+	m.sample("scan_progress", float64(state.Current), "stage", state.Stage, "type", "current")
+	m.sample("scan_progress", float64(state.Total), "stage", state.Stage, "type", "total")
+
+	if s.Stats != nil {
+		if st := s.Stats(); !st.EndTime.IsZero() {
+			m.gauge("scan_timestamp_seconds", "Unix time the last scan finished.", float64(st.EndTime.Unix()))
+			m.gauge("scan_duration_seconds", "Duration of the last scan.", st.Duration().Seconds())
+			m.family("scan_stage_duration_seconds", "gauge", "Duration of each stage of the last scan.")
+			m.sample("scan_stage_duration_seconds", st.PingDuration.Seconds(), "stage", utils.StagePing)
+			m.sample("scan_stage_duration_seconds", st.DownloadDuration.Seconds(), "stage", utils.StageDownload)
+			m.sample("scan_stage_duration_seconds", st.UploadDuration.Seconds(), "stage", utils.StageUpload)
+			m.family("scan_ips", "gauge", "Number of IPs in each step of the last scan.")
+			m.sample("scan_ips", float64(st.Tested), "state", "tested")
+			m.sample("scan_ips", float64(st.Available), "state", "available")
+			m.sample("scan_ips", float64(st.Filtered), "state", "filtered")
+			m.sample("scan_ips", float64(st.Results), "state", "results")
+		}
+	}
+
+	data, _, ok := s.Latest()
+	if !ok {
+		return m.err
+	}
+	m.gauge("results", "Number of IPs in the latest results.", float64(len(data)))
+	families := []struct {
+		name, help string
+		value      func(v *utils.CloudflareIPData) float64
+	}{
+		{"ip_rank", "Rank of the IP in the latest results (1 is the best).", nil},
+		{"ip_latency_seconds", "Average latency of the IP.", func(v *utils.CloudflareIPData) float64 { return v.Delay.Seconds() }},
+		{"ip_loss_ratio", "Packet loss ratio of the IP.", func(v *utils.CloudflareIPData) float64 { return float64(v.LossRate()) }},
+		{"ip_download_speed_bytes_per_second", "Download speed of the IP in bytes per second.", func(v *utils.CloudflareIPData) float64 { return v.DownloadSpeed }},
+		{"ip_upload_speed_bytes_per_second", "Upload speed of the IP in bytes per second.", func(v *utils.CloudflareIPData) float64 { return v.UploadSpeed }},
+	}
+	for _, f := range families {
+		m.family(f.name, "gauge", f.help)
+		for i := range data {
+			value := float64(i + 1)
+			if f.value != nil {
+				value = f.value(&data[i])
+			}
+			m.sample(f.name, value, "ip", data[i].IP.String(), "colo", data[i].Colo)
+		}
+	}
+	return m.err
+}
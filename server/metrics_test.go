@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/task"
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// TestServer_WriteMetrics 测试 Prometheus 指标输出
+func TestServer_WriteMetrics(t *testing.T) {
+	srv := New(context.Background(), testScan, utils.ExportMeta{}, &utils.Progress{})
+	start := time.Unix(1700000000, 0)
+	srv.Stats = func() task.ScanStats {
+		return task.ScanStats{
+			Tested: 256, Available: 20, Filtered: 2, Results: 2,
+			PingDuration: 3 * time.Second, DownloadDuration: 7 * time.Second,
+			StartTime: start, EndTime: start.Add(10 * time.Second),
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := srv.writeMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "cfst_ip_") {
+		t.Errorf("per-IP metrics should be absent before the first scan:\n%s", buf.String())
+	}
+
+	srv.Scan(context.Background())
+	buf.Reset()
+	if err := srv.writeMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"# TYPE cfst_scan_running gauge\ncfst_scan_running 0\n",
+		"cfst_scan_timestamp_seconds 1.70000001e+09\n",
+		"cfst_scan_duration_seconds 10\n",
+		`cfst_scan_stage_duration_seconds{stage="ping"} 3` + "\n",
+		`cfst_scan_ips{state="tested"} 256` + "\n",
+		`cfst_scan_ips{state="available"} 20` + "\n",
+		"cfst_results 2\n",
+		`cfst_ip_rank{ip="2.2.2.2",colo=""} 2` + "\n",
+		`cfst_ip_latency_seconds{ip="1.1.1.1",colo=""} 0.1` + "\n",
+		`cfst_ip_loss_ratio{ip="2.2.2.2",colo=""} 0.25` + "\n",
+		`cfst_ip_download_speed_bytes_per_second{ip="1.1.1.1",colo=""} 1.048576e+07` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics missing %q\n%s", want, got)
+		}
+	}
+}
+
+// TestServer_WriteMetricsConcurrent 测试多个请求同时读取最新测速结果（配合 -race 检查数据竞争）
+func TestServer_WriteMetricsConcurrent(t *testing.T) {
+	srv := New(context.Background(), testScan, utils.ExportMeta{}, &utils.Progress{})
+	srv.Scan(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			if err := srv.writeMetrics(&buf); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/task"
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// Server 内嵌 HTTP API 服务：触发测速、查询进度、获取最新测速结果
+//
+//	POST /scan      开始测速（正在测速时返回 409）
+//	GET  /progress  测速进度（与进度条显示的计数一致）
+//	GET  /results   最新测速结果（默认 JSON，可通过 ?format=csv 等指定格式）
+//	GET  /best      最优 IP（纯文本）
+//	GET  /metrics   Prometheus 指标
+type Server struct {
+	OnResult func(ctx context.Context, meta utils.ExportMeta, data utils.DownloadSpeedSet) // 通过 API 触发的测速完成后的回调（可为空）
+	Stats    func() task.ScanStats                                                         // 最近一次测速的统计，用于输出指标（可为空）
+
+	ctx      context.Context                                           // 通过 API 触发的测速使用的上下文
+	scan     func(ctx context.Context) (utils.DownloadSpeedSet, error) // 单次测速（通常为 Scanner.Run）
+	meta     utils.ExportMeta                                          // 结果元数据模板
+	progress *utils.Progress                                           // 测速进度
+
+	scanMu sync.Mutex // 同一时间只允许一次测速
+
+	mu         sync.RWMutex
+	hasResult  bool                   // 是否已有完成的测速
+	latest     utils.DownloadSpeedSet // 最新测速结果
+	latestMeta utils.ExportMeta       // 最新测速结果的元数据
+	lastErr    string                 // 最近一次测速失败的原因（成功后清空）
+}
+
+// New 创建 API 服务，ctx 取消后通过 API 触发的测速也会中止
+func New(ctx context.Context, scan func(ctx context.Context) (utils.DownloadSpeedSet, error), meta utils.ExportMeta, progress *utils.Progress) *Server {
+	return &Server{ctx: ctx, scan: scan, meta: meta, progress: progress}
+}
+
+// Scan 执行一次测速并记录为最新结果（与通过 API 触发的测速互斥）
+// 被中断或失败的测速结果不会记录
+func (s *Server) Scan(ctx context.Context) (utils.DownloadSpeedSet, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	data, _, err := s.runScan(ctx)
+	return data, err
+}
+
+// runScan 执行测速，调用前需持有 scanMu
+func (s *Server) runScan(ctx context.Context) (utils.DownloadSpeedSet, utils.ExportMeta, error) {
+	meta := s.meta
+	meta.StartTime = time.Now()
+	s.progress.Start()
+	data, err := s.scan(ctx)
+	s.progress.Finish()
+	meta.EndTime = time.Now()
+	if err != nil {
+		s.mu.Lock()
+		s.lastErr = err.Error()
+		s.mu.Unlock()
+		return nil, meta, err
+	}
+	if ctx.Err() == nil {
+		s.mu.Lock()
+		s.hasResult, s.latest, s.latestMeta = true, cloneResult(data), meta // 保存快照，调用方后续对结果的修改不影响 API
+		s.lastErr = ""
+		s.mu.Unlock()
+	}
+	return data, meta, nil
+}
+
+// Latest 返回最新测速结果的副本及其元数据，尚无结果时 ok 为 false
+func (s *Server) Latest() (data utils.DownloadSpeedSet, meta utils.ExportMeta, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneResult(s.latest), s.latestMeta, s.hasResult
+}
+
+// cloneResult 复制测速结果列表，延迟测速数据测速完成后不再修改，可共享
+func cloneResult(data utils.DownloadSpeedSet) utils.DownloadSpeedSet {
+	if data == nil {
+		return nil
+	}
+	return append(make(utils.DownloadSpeedSet, 0, len(data)), data...)
+}
+
+// Handler 返回 API 的 HTTP 处理器
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan", s.handleScan)
+	mux.HandleFunc("/progress", s.handleProgress)
+	mux.HandleFunc("/results", s.handleResults)
+	mux.HandleFunc("/best", s.handleBest)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return mux
+}
+
+// ListenAndServe 监听 addr 提供 API 服务，ctx 取消后关闭服务
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !s.scanMu.TryLock() {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "正在测速中"})
+		return
+	}
+	go func() {
+		defer s.scanMu.Unlock()
+		data, meta, err := s.runScan(s.ctx)
+		if s.OnResult != nil && err == nil && s.ctx.Err() == nil {
+			s.OnResult(s.ctx, meta, data)
+		}
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	_, meta, ok := s.Latest()
+	s.mu.RLock()
+	lastErr := s.lastErr
+	s.mu.RUnlock()
+	res := struct {
+		utils.ProgressState
+		LastStartTime *time.Time `json:"last_start_time,omitempty"` // 最新测速结果的开始时间
+		LastEndTime   *time.Time `json:"last_end_time,omitempty"`   // 最新测速结果的结束时间
+		LastError     string     `json:"last_error,omitempty"`      // 最近一次测速失败的原因
+	}{ProgressState: s.progress.State(), LastError: lastErr}
+	if ok {
+		res.LastStartTime, res.LastEndTime = &meta.StartTime, &meta.EndTime
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	data, meta, ok := s.Latest()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "暂无测速结果"})
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = utils.FormatJSON
+	}
+	if format == utils.FormatJSON || format == utils.FormatNDJSON {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	if err := utils.ExportTo(w, format, meta, data); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
+
+func (s *Server) handleBest(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	data, _, _ := s.Latest()
+	if len(data) == 0 {
+		http.Error(w, "暂无测速结果", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(data[0].IP.String() + "\n"))
+}
+
+// allowMethod 检查请求方法，不允许时返回 405
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "不支持的请求方法"})
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+func testResult() utils.DownloadSpeedSet {
+	return utils.DownloadSpeedSet{
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4, Delay: 100 * time.Millisecond}, DownloadSpeed: 10 * 1024 * 1024},
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("2.2.2.2")}, Sended: 4, Received: 3, Delay: 200 * time.Millisecond}},
+	}
+}
+
+func testScan(context.Context) (utils.DownloadSpeedSet, error) {
+	return testResult(), nil
+}
+
+func get(t *testing.T, ts *httptest.Server, path string) (int, string) {
+	t.Helper()
+	res, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+// TestServer_NoResult 测试尚无测速结果时的响应
+func TestServer_NoResult(t *testing.T) {
+	srv := New(context.Background(), nil, utils.ExportMeta{}, &utils.Progress{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	for _, path := range []string{"/results", "/best"} {
+		if code, _ := get(t, ts, path); code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, expected 404", path, code)
+		}
+	}
+	if code, body := get(t, ts, "/progress"); code != http.StatusOK || !strings.Contains(body, `"running":false`) {
+		t.Errorf("GET /progress = %d %s", code, body)
+	}
+	if code, _ := get(t, ts, "/scan"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /scan = %d, expected 405", code)
+	}
+}
+
+// TestServer_ScanAndResults 测试通过 API 触发测速并获取结果
+func TestServer_ScanAndResults(t *testing.T) {
+	progress := &utils.Progress{}
+	release := make(chan struct{})
+	scan := func(ctx context.Context) (utils.DownloadSpeedSet, error) {
+		<-release
+		return testResult(), nil
+	}
+	srv := New(context.Background(), scan, utils.ExportMeta{Version: "v1.0.0"}, progress)
+	done := make(chan utils.DownloadSpeedSet, 1)
+	srv.OnResult = func(_ context.Context, _ utils.ExportMeta, data utils.DownloadSpeedSet) {
+		done <- data
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/scan", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /scan = %d, expected 202", res.StatusCode)
+	}
+
+	// 测速进行中：再次触发返回 409，进度显示正在测速
+	res, err = http.Post(ts.URL+"/scan", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("second POST /scan = %d, expected 409", res.StatusCode)
+	}
+	if _, body := get(t, ts, "/progress"); !strings.Contains(body, `"running":true`) {
+		t.Errorf("GET /progress = %s, expected running", body)
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not finish")
+	}
+
+	if code, body := get(t, ts, "/best"); code != http.StatusOK || body != "1.1.1.1\n" {
+		t.Errorf("GET /best = %d %q, expected 1.1.1.1", code, body)
+	}
+
+	code, body := get(t, ts, "/results")
+	if code != http.StatusOK {
+		t.Fatalf("GET /results = %d", code)
+	}
+	var results struct {
+		Version string           `json:"version"`
+		Count   int              `json:"count"`
+		Results []utils.IPRecord `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(body), &results); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if results.Version != "v1.0.0" || results.Count != 2 || results.Results[0].IP != "1.1.1.1" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	if code, body := get(t, ts, "/results?format=csv"); code != http.StatusOK || !strings.Contains(body, "2.2.2.2") {
+		t.Errorf("GET /results?format=csv = %d %s", code, body)
+	}
+	if code, _ := get(t, ts, "/results?format=xml"); code != http.StatusBadRequest {
+		t.Errorf("GET /results?format=xml = %d, expected 400", code)
+	}
+	if _, body := get(t, ts, "/progress"); !strings.Contains(body, `"running":false`) || !strings.Contains(body, "last_end_time") {
+		t.Errorf("GET /progress = %s", body)
+	}
+}
+
+// TestServer_Scan_Canceled 测试被中断的测速结果不会记录
+func TestServer_Scan_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	srv := New(ctx, testScan, utils.ExportMeta{}, nil)
+	srv.Scan(ctx)
+	if _, _, ok := srv.Latest(); ok {
+		t.Error("canceled scan should not be recorded")
+	}
+}
+
+// TestServer_Scan_Snapshot 测试调用方修改测速结果（如重新排序）不影响 API 返回的最新结果
+func TestServer_Scan_Snapshot(t *testing.T) {
+	srv := New(context.Background(), testScan, utils.ExportMeta{}, &utils.Progress{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	data, _ := srv.Scan(context.Background())
+	data[0], data[1] = data[1], data[0]
+	if _, body := get(t, ts, "/best"); body != "1.1.1.1\n" {
+		t.Errorf("GET /best = %q, expected 1.1.1.1", body)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, body := get(t, ts, "/results?format=csv"); code != http.StatusOK || !strings.Contains(body, "2.2.2.2") {
+				t.Errorf("GET /results = %d %s", code, body)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestServer_Scan_Error 测试测速失败时不记录结果，并通过 /progress 返回失败原因
+func TestServer_Scan_Error(t *testing.T) {
+	scan := func(context.Context) (utils.DownloadSpeedSet, error) { return nil, errors.New("ip.txt not found") }
+	srv := New(context.Background(), scan, utils.ExportMeta{}, &utils.Progress{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	if _, err := srv.Scan(context.Background()); err == nil {
+		t.Fatal("expected scan error")
+	}
+	if code, _ := get(t, ts, "/results"); code != http.StatusNotFound {
+		t.Errorf("GET /results = %d, expected 404", code)
+	}
+	if _, body := get(t, ts, "/progress"); !strings.Contains(body, `"last_error":"ip.txt not found"`) {
+		t.Errorf("GET /progress = %s", body)
+	}
+}
@@ -0,0 +1,75 @@
+package task
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDownloadRoutines = 1
+	maxDownloadRoutines     = 32
+	// 并发下载测速的总带宽低于单独测速时最高带宽的该倍数时，视为相互干扰（上行带宽已跑满）
+	interferenceRatio = 1.2
+	// 测速期间其他测速的下载量低于本次的该比例时，视为单独测速
+	soloRatio = 0.1
+)
+
+// bandwidthMeter 统计下载量，parent 不为空时同时计入 parent（用于统计所有并发测速的总下载量）
+type bandwidthMeter struct {
+	bytes  int64 // 已下载的字节数（原子操作）
+	parent *bandwidthMeter
+}
+
+// add 记录下载的字节数（m 为空时不记录）
+func (m *bandwidthMeter) add(n int) {
+	for ; m != nil; m = m.parent {
+		atomic.AddInt64(&m.bytes, int64(n))
+	}
+}
+
+// total 返回已下载的字节数
+func (m *bandwidthMeter) total() int64 {
+	if m == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&m.bytes)
+}
+
+// downloadScheduler 根据总带宽判断并发下载测速是否相互干扰，调用方需自行加锁
+type downloadScheduler struct {
+	routines int     // 最大并发数
+	solo     float64 // 单独测速时的最高总带宽（字节/秒）
+	peak     float64 // 观测到的最高总带宽（字节/秒）
+	serial   bool    // 是否已回退为逐个测速
+}
+
+// limit 返回当前允许的并发数：取得单独测速的带宽基准前及回退后均为 1
+func (s *downloadScheduler) limit() int {
+	if s.serial || s.solo == 0 {
+		return 1
+	}
+	return s.routines
+}
+
+// observe 记录一次下载测速期间的总带宽，返回该次测速结果是否受到并发测速的干扰
+// own 为本次测速的下载量，total 为测速期间所有测速的总下载量，d 为测速耗时
+func (s *downloadScheduler) observe(own, total int64, d time.Duration) bool {
+	if d <= 0 {
+		return false
+	}
+	aggregate := float64(total) / d.Seconds()
+	if aggregate > s.peak {
+		s.peak = aggregate
+	}
+	if float64(total-own) <= float64(own)*soloRatio { // 单独测速，作为带宽基准
+		if aggregate > s.solo {
+			s.solo = aggregate
+		}
+		return false
+	}
+	if s.solo > 0 && aggregate < s.solo*interferenceRatio {
+		s.serial = true
+		return true
+	}
+	return false
+}
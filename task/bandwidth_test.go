@@ -0,0 +1,79 @@
+package task
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// TestBandwidthMeter 测试下载量同时计入上级统计
+func TestBandwidthMeter(t *testing.T) {
+	global := &bandwidthMeter{}
+	a, b := &bandwidthMeter{parent: global}, &bandwidthMeter{parent: global}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); a.add(10) }()
+		go func() { defer wg.Done(); b.add(5) }()
+	}
+	wg.Wait()
+	var none *bandwidthMeter
+	none.add(1) // 为空时不记录
+
+	if a.total() != 1000 || b.total() != 500 || global.total() != 1500 {
+		t.Errorf("totals = %d, %d, %d, expected 1000, 500, 1500", a.total(), b.total(), global.total())
+	}
+}
+
+// TestDownloadScheduler 测试根据总带宽判断并发测速是否相互干扰
+func TestDownloadScheduler(t *testing.T) {
+	const mb = 1024 * 1024
+	s := &downloadScheduler{routines: 4}
+	if s.limit() != 1 {
+		t.Fatalf("limit() before baseline = %d, expected 1", s.limit())
+	}
+
+	// 单独测速：10 MB/s 作为基准
+	if s.observe(10*mb, 10*mb, time.Second) || s.solo != 10*mb || s.limit() != 4 {
+		t.Fatalf("after solo test: solo = %v, limit = %d", s.solo, s.limit())
+	}
+	// 并发测速：总带宽 30 MB/s，未跑满
+	if s.observe(10*mb, 30*mb, time.Second) || s.serial {
+		t.Fatal("30 MB/s aggregate should not be treated as interference")
+	}
+	// 并发测速：总带宽 11 MB/s，与单独测速相当，视为跑满
+	if !s.observe(5*mb, 11*mb, time.Second) || !s.serial || s.limit() != 1 {
+		t.Fatal("11 MB/s aggregate should fall back to serial")
+	}
+	if s.peak != 30*mb {
+		t.Errorf("peak = %v, expected %v", s.peak, 30*mb)
+	}
+}
+
+// TestTestDownloadSpeed_Concurrent 测试并发下载测速完成所有 IP 的测速
+func TestTestDownloadSpeed_Concurrent(t *testing.T) {
+	cfg, _ := downloadServer(t, 256*1024)
+	cfg.TestCount = 4
+	cfg.DownloadRoutines = 3
+	cfg.Timeout = time.Second
+
+	ipSet := make(utils.PingDelaySet, 0, 6)
+	for i := 0; i < 6; i++ {
+		ipSet = append(ipSet, utils.CloudflareIPData{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("127.0.0.1")}, Sended: 4, Received: 4}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	speedSet := NewScanner(cfg).TestDownloadSpeed(ctx, ipSet)
+
+	if ctx.Err() != nil {
+		t.Fatal("concurrent download test did not finish in time")
+	}
+	if len(speedSet) != 6 { // 未指定速度下限时返回全部数据
+		t.Errorf("expected 6 results, got %d", len(speedSet))
+	}
+}
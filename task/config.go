@@ -0,0 +1,116 @@
+package task
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// Config 测速配置，每个 Scanner 持有一份独立的配置
+type Config struct {
+	// 延迟测速
+	Routines  int // 延迟测速线程数
+	PingTimes int // 单个 IP 延迟测速次数
+	TCPPort   int // 测速端口
+
+	TLSPing bool // 是否使用 TLS 握手进行延迟测速（记录 TCP 连接、TLS 握手耗时及证书信息）
+
+	Protocol string // HTTPing、下载及上传测速使用的 HTTP 协议版本（h1 / h2）
+	SNI      string // TLS 握手使用的 SNI（为空时使用 Host 或测速地址的域名）
+	Host     string // HTTP 请求的 Host 头（为空时使用测速地址的域名）
+	Insecure bool   // 是否跳过证书验证
+
+	// 请求内容（HTTPing 及下载测速）
+	Headers        http.Header // 自定义请求头（覆盖默认 User-Agent，上传测速同样携带）
+	HttpingMethod  string      // HTTPing 请求方法（默认 HEAD）
+	DownloadMethod string      // 下载测速请求方法（默认 GET）
+	Body           string      // 请求体（为空时不发送）
+	CookieJar      bool        // 是否启用 Cookie 容器（同一 IP 的多次请求及重定向之间保留 Cookie）
+
+	// HTTPing
+	Httping            bool          // 是否使用 HTTP 协议进行延迟测速
+	HttpingStatusCodes StatusCodes   // 有效的 HTTP 状态码（默认 200,301,302）
+	HttpingCFColo      string        // 匹配指定地区（英文逗号分隔）
+	HttpingCheck       ResponseCheck // 响应内容校验规则
+
+	// 下载测速
+	URL                 string        // 测速地址
+	Timeout             time.Duration // 单个 IP 下载测速最长时间
+	DisableDownload     bool          // 是否禁用下载测速
+	TestCount           int           // 下载测速数量
+	MinSpeed            float64       // 下载速度下限（MB/s）
+	DownloadRoutines    int           // 下载测速并发数（为 1 时逐个测速）
+	DownloadConns       int           // 单个 IP 下载测速的连接数
+	DownloadStatusCodes StatusCodes   // 下载测速有效的 HTTP 状态码（默认 200）
+
+	// 上传测速
+	UploadURL  string // 上传测速地址（为空时不进行上传测速）
+	UploadSize int64  // 单个 IP 上传测速的数据量（字节）
+
+	// IP 段数据
+	TestAll bool   // 是否测试所有 IP（而非随机采样）
+	IPFile  string // IP 段数据文件名
+	IPText  string // 直接指定的 IP 段数据
+
+	// 过滤条件
+	MaxDelay    time.Duration // 平均延迟上限
+	MinDelay    time.Duration // 平均延迟下限
+	MaxLossRate float32       // 丢包几率上限
+	MaxJitter   time.Duration // 抖动上限
+
+	// 排序
+	Sort         utils.SortOrder // 过滤后的延迟测速结果及最终结果的排序方式（为空时使用默认排序）
+	SortByJitter bool            // 是否按抖动排序（低抖动优先），未指定 Sort 时有效
+
+	Debug bool // 是否开启调试模式
+}
+
+// DefaultConfig 返回默认测速配置
+func DefaultConfig() Config {
+	return Config{
+		Routines:            defaultRoutines,
+		PingTimes:           defaultPingTimes,
+		TCPPort:             defaultPort,
+		Protocol:            ProtocolHTTP1,
+		HttpingMethod:       defaultHttpingMethod,
+		DownloadMethod:      defaultDownloadMethod,
+		URL:                 defaultURL,
+		Timeout:             defaultTimeout,
+		DisableDownload:     defaultDisableDownload,
+		TestCount:           defaultTestNum,
+		MinSpeed:            defaultMinSpeed,
+		DownloadRoutines:    defaultDownloadRoutines,
+		DownloadConns:       defaultDownloadConns,
+		HttpingStatusCodes:  defaultHttpingStatusCodes,
+		DownloadStatusCodes: defaultDownloadStatusCodes,
+		UploadSize:          defaultUploadSize,
+		IPFile:              defaultInputFile,
+		MaxDelay:            utils.DefaultMaxDelay,
+		MinDelay:            utils.DefaultMinDelay,
+		MaxLossRate:         utils.DefaultMaxLossRate,
+		MaxJitter:           utils.DefaultMaxJitter,
+	}
+}
+
+// Mode 返回延迟测速模式名称
+func (c *Config) Mode() string {
+	if c.Httping {
+		return "httping"
+	}
+	if c.TLSPing {
+		return "tlsping"
+	}
+	return "tcping"
+}
+
+// order 返回过滤后的延迟测速结果及最终结果的排序方式，为空时使用默认排序
+func (c *Config) order() utils.SortOrder {
+	if len(c.Sort) > 0 {
+		return c.Sort
+	}
+	if c.SortByJitter { // 稳定排序，抖动相同时保持默认排序的顺序
+		return utils.SortOrder{{Name: "jitter"}}
+	}
+	return nil
+}
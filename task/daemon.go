@@ -0,0 +1,114 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// Daemon 守护模式：按间隔重复执行完整测速流程，并保留上次触发时的最优 IP
+// 仅在最优 IP 变化或劣化超过阈值时触发 OnChange（输出文件、发布结果等）
+type Daemon struct {
+	Scan      func(ctx context.Context) (utils.DownloadSpeedSet, error)                              // 单次测速（默认为 Scanner.Run）
+	Interval  time.Duration                                                                          // 两轮测速的间隔
+	Threshold float64                                                                                // 劣化阈值（百分比），<= 0 时仅在最优 IP 变化时触发
+	OnChange  func(ctx context.Context, start time.Time, data utils.DownloadSpeedSet, reason string) // 触发时的回调，start 为该轮测速的开始时间
+
+	mu   sync.Mutex              // 守护模式与其他来源（如 API 触发的测速）的结果依次处理
+	best *utils.CloudflareIPData // 上次触发时的最优 IP 及其测速结果
+}
+
+// NewDaemon 创建使用 scanner 测速的守护模式实例
+func NewDaemon(scanner *Scanner, interval time.Duration, threshold float64, onChange func(ctx context.Context, start time.Time, data utils.DownloadSpeedSet, reason string)) *Daemon {
+	return &Daemon{
+		Scan:      scanner.Run,
+		Interval:  interval,
+		Threshold: threshold,
+		OnChange:  onChange,
+	}
+}
+
+// Run 循环测速直到 ctx 取消，被中断的一轮测速结果不会触发 OnChange
+func (d *Daemon) Run(ctx context.Context) {
+	for round := 1; ; round++ {
+		utils.Cyan.Printf("[信息] 守护模式：第 %d 轮测速开始（%s）\n\n", round, time.Now().Format("2006-01-02 15:04:05"))
+		start := time.Now()
+		data, err := d.Scan(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil { // 本轮测速失败（如 IP 段数据文件暂时无法读取），等待下一轮
+			utils.Red.Printf("[错误] 守护模式：本轮测速失败：%v\n", err)
+		} else {
+			d.Handle(ctx, start, data)
+		}
+
+		fmt.Printf("\n[信息] 守护模式：%s 后开始下一轮测速（按下 Ctrl+C 退出）\n", d.Interval)
+		timer := time.NewTimer(d.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Handle 对比测速结果与上次触发时的最优 IP，需要时触发 OnChange，start 为该次测速的开始时间
+// 可在其他 goroutine 中调用（如 API 触发的测速），与守护模式各轮的结果依次处理
+func (d *Daemon) Handle(ctx context.Context, start time.Time, data utils.DownloadSpeedSet) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reason, ok := d.Check(data)
+	if !ok {
+		utils.Green.Printf("[信息] 守护模式：%s\n", reason)
+		return
+	}
+	utils.Yellow.Printf("[信息] 守护模式：%s，更新测速结果...\n", reason)
+	if d.OnChange != nil {
+		d.OnChange(ctx, start, data, reason)
+	}
+}
+
+// Check 对比本轮测速结果与上次触发时的最优 IP，返回原因及是否需要触发
+// 需要触发时会将本轮最优 IP 记为新的基准
+func (d *Daemon) Check(data utils.DownloadSpeedSet) (string, bool) {
+	if len(data) == 0 {
+		return "本轮测速结果 IP 数量为 0，保留上次的最优 IP", false
+	}
+	current := data[0]
+	reason, changed := d.compare(data)
+	if changed {
+		d.best = &current
+	}
+	return reason, changed
+}
+
+func (d *Daemon) compare(data utils.DownloadSpeedSet) (string, bool) {
+	current := data[0]
+	if d.best == nil {
+		return fmt.Sprintf("首次测速，最优 IP 为 %s", current.IP), true
+	}
+	prev := *d.best
+	if current.IP.String() != prev.IP.String() {
+		return fmt.Sprintf("最优 IP 由 %s 变为 %s", prev.IP, current.IP), true
+	}
+	if d.Threshold > 0 {
+		if prev.DownloadSpeed > 0 {
+			if drop := (prev.DownloadSpeed - current.DownloadSpeed) / prev.DownloadSpeed * 100; drop > d.Threshold {
+				return fmt.Sprintf("最优 IP %s 下载速度下降 %.1f%%（%.2f → %.2f MB/s）", current.IP, drop,
+					prev.DownloadSpeed/1024/1024, current.DownloadSpeed/1024/1024), true
+			}
+		}
+		if prev.Delay > 0 {
+			if rise := float64(current.Delay-prev.Delay) / float64(prev.Delay) * 100; rise > d.Threshold {
+				return fmt.Sprintf("最优 IP %s 平均延迟上升 %.1f%%（%s → %s）", current.IP, rise,
+					prev.Delay.Round(time.Millisecond), current.Delay.Round(time.Millisecond)), true
+			}
+		}
+	}
+	return fmt.Sprintf("最优 IP %s 未变化", current.IP), false
+}
@@ -0,0 +1,118 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+func daemonResult(ip string, delay time.Duration, speedMB float64) utils.DownloadSpeedSet {
+	return utils.DownloadSpeedSet{{
+		PingData:      &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP(ip)}, Sended: 4, Received: 4, Delay: delay},
+		DownloadSpeed: speedMB * 1024 * 1024,
+	}}
+}
+
+// TestDaemon_Check 测试最优 IP 变化及劣化检测
+func TestDaemon_Check(t *testing.T) {
+	d := &Daemon{Threshold: 20}
+	steps := []struct {
+		name string
+		data utils.DownloadSpeedSet
+		want bool
+	}{
+		{"首次测速", daemonResult("1.1.1.1", 100*time.Millisecond, 10), true},
+		{"未变化", daemonResult("1.1.1.1", 110*time.Millisecond, 9), false},
+		{"空结果保留上次", nil, false},
+		{"下载速度下降超过阈值", daemonResult("1.1.1.1", 100*time.Millisecond, 7), true},
+		{"以新基准对比未变化", daemonResult("1.1.1.1", 100*time.Millisecond, 6), false},
+		{"延迟上升超过阈值", daemonResult("1.1.1.1", 150*time.Millisecond, 7), true},
+		{"最优 IP 变化", daemonResult("2.2.2.2", 150*time.Millisecond, 7), true},
+	}
+	for _, s := range steps {
+		reason, got := d.Check(s.data)
+		if got != s.want {
+			t.Errorf("%s: Check() = %v (%s), expected %v", s.name, got, reason, s.want)
+		}
+	}
+}
+
+// TestDaemon_Check_NoThreshold 测试阈值为 0 时仅在最优 IP 变化时触发
+func TestDaemon_Check_NoThreshold(t *testing.T) {
+	d := &Daemon{}
+	d.Check(daemonResult("1.1.1.1", 100*time.Millisecond, 10))
+	if reason, got := d.Check(daemonResult("1.1.1.1", 900*time.Millisecond, 1)); got {
+		t.Errorf("Check() = true (%s), expected false", reason)
+	}
+}
+
+// TestDaemon_Run 测试守护模式循环测速，仅在变化时触发回调，取消后退出
+func TestDaemon_Run(t *testing.T) {
+	results := []utils.DownloadSpeedSet{
+		daemonResult("1.1.1.1", 100*time.Millisecond, 10),
+		daemonResult("1.1.1.1", 100*time.Millisecond, 10),
+		daemonResult("2.2.2.2", 100*time.Millisecond, 10),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	round := 0
+	var triggered []string
+	d := &Daemon{
+		Interval: time.Millisecond,
+		Scan: func(ctx context.Context) (utils.DownloadSpeedSet, error) {
+			if round == len(results) {
+				cancel()
+				return nil, nil
+			}
+			round++
+			if round == 2 { // 测速失败的一轮不触发回调，也不影响后续测速
+				return nil, errors.New("scan failed")
+			}
+			return results[round-1], nil
+		},
+		OnChange: func(_ context.Context, _ time.Time, data utils.DownloadSpeedSet, _ string) {
+			triggered = append(triggered, data[0].IP.String())
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Daemon.Run did not return after cancel")
+	}
+
+	if len(triggered) != 2 || triggered[0] != "1.1.1.1" || triggered[1] != "2.2.2.2" {
+		t.Errorf("triggered = %v, expected [1.1.1.1 2.2.2.2]", triggered)
+	}
+}
+
+// TestDaemon_Handle 测试多个 goroutine 同时提交测速结果时依次处理，相同的最优 IP 只触发一次
+func TestDaemon_Handle(t *testing.T) {
+	var calls int
+	d := &Daemon{OnChange: func(context.Context, time.Time, utils.DownloadSpeedSet, string) { calls++ }}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Handle(context.Background(), time.Now(), daemonResult("1.1.1.1", 100*time.Millisecond, 10))
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("OnChange called %d times, expected 1", calls)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/XIU2/CloudflareSpeedTest/utils"
@@ -22,82 +23,92 @@ const (
 	defaultDisableDownload         = false
 	defaultTestNum                 = 10
 	defaultMinSpeed        float64 = 0.0
-)
-
-var (
-	URL     = defaultURL
-	Timeout = defaultTimeout
-	Disable = defaultDisableDownload
-
-	TestCount = defaultTestNum
-	MinSpeed  = defaultMinSpeed
+	defaultDownloadConns           = 1
+	maxDownloadConns               = 16
 )
 
 // checkDownloadDefault 检查并修正下载测试默认参数
-func checkDownloadDefault() {
-	if URL == "" {
-		URL = defaultURL
+func (c *Config) checkDownloadDefault() {
+	if c.URL == "" {
+		c.URL = defaultURL
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.TestCount <= 0 {
+		c.TestCount = defaultTestNum
 	}
-	if Timeout <= 0 {
-		Timeout = defaultTimeout
+	if c.MinSpeed <= 0.0 {
+		c.MinSpeed = defaultMinSpeed
 	}
-	if TestCount <= 0 {
-		TestCount = defaultTestNum
+	if c.DownloadConns <= 0 {
+		c.DownloadConns = defaultDownloadConns
+	} else if c.DownloadConns > maxDownloadConns {
+		c.DownloadConns = maxDownloadConns
 	}
-	if MinSpeed <= 0.0 {
-		MinSpeed = defaultMinSpeed
+	if c.DownloadRoutines <= 0 {
+		c.DownloadRoutines = defaultDownloadRoutines
+	} else if c.DownloadRoutines > maxDownloadRoutines {
+		c.DownloadRoutines = maxDownloadRoutines
 	}
 }
 
 // TestDownloadSpeed 测试下载速度
-func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSet) {
-	checkDownloadDefault()
-	if Disable {
+// ctx 取消后中止当前测速并不再测试后续 IP，返回已获取的结果
+func (s *Scanner) TestDownloadSpeed(ctx context.Context, ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSet) {
+	if s.cfg.DisableDownload {
 		return utils.DownloadSpeedSet(ipSet)
 	}
 	if len(ipSet) <= 0 {
 		utils.Yellow.Println("[信息] 延迟测速结果 IP 数量为 0，跳过下载测速。")
 		return
 	}
-	testNum := TestCount
+	testCount := s.cfg.TestCount
+	testNum := testCount
 	// 如果 IP 数量不足或指定了速度下限，则测试全部 IP
-	if len(ipSet) < TestCount || MinSpeed > 0 {
+	if len(ipSet) < testCount || s.cfg.MinSpeed > 0 {
 		testNum = len(ipSet)
 	}
-	if testNum < TestCount {
-		TestCount = testNum
+	if testNum < testCount {
+		testCount = testNum
 	}
 
-	utils.Cyan.Printf("开始下载测速（下限：%.2f MB/s, 数量：%d, 队列：%d）\n", MinSpeed, TestCount, testNum)
+	if s.cfg.DownloadRoutines > 1 {
+		utils.Cyan.Printf("开始下载测速（下限：%.2f MB/s, 数量：%d, 队列：%d, 并发：%d）\n", s.cfg.MinSpeed, testCount, testNum, s.cfg.DownloadRoutines)
+	} else {
+		utils.Cyan.Printf("开始下载测速（下限：%.2f MB/s, 数量：%d, 队列：%d）\n", s.cfg.MinSpeed, testCount, testNum)
+	}
 	// 调整进度条宽度以对齐
 	bar_a := len(strconv.Itoa(len(ipSet)))
 	bar_b := "     "
 	for i := 0; i < bar_a; i++ {
 		bar_b += " "
 	}
-	bar := utils.NewBar(TestCount, bar_b, "")
+	bar := utils.NewBar(testCount, bar_b, "").Report(s.progress, utils.StageDownload)
 
-	// 逐个 IP 测试下载速度
-	for i := 0; i < testNum; i++ {
-		speed, colo := downloadHandler(ipSet[i].IP)
-		ipSet[i].DownloadSpeed = speed
-		if ipSet[i].Colo == "" {
-			ipSet[i].Colo = colo
-		}
-		// 按速度下限过滤结果
-		if speed >= MinSpeed*1024*1024 {
-			bar.Grow(1, "")
-			speedSet = append(speedSet, ipSet[i])
-			if len(speedSet) == TestCount {
+	if s.cfg.DownloadRoutines > 1 {
+		speedSet = s.testDownloadConcurrent(ctx, ipSet[:testNum], testCount, bar)
+	} else {
+		// 逐个 IP 测试下载速度
+		for i := 0; i < testNum && ctx.Err() == nil; i++ {
+			result := s.downloadHandler(ctx, ipSet[i].IP, nil)
+			if ctx.Err() != nil { // 测速被中断，结果不完整，直接丢弃
 				break
 			}
+			if s.recordSpeed(&ipSet[i], result) {
+				bar.Grow(1, "")
+				speedSet = append(speedSet, ipSet[i])
+				if len(speedSet) == testCount {
+					break
+				}
+			}
 		}
 	}
 	bar.Done()
 	// 没有指定速度下限时返回所有数据
-	if MinSpeed == 0.00 {
+	if s.cfg.MinSpeed == 0.00 {
 		speedSet = utils.DownloadSpeedSet(ipSet)
-	} else if utils.Debug && len(speedSet) == 0 {
+	} else if s.cfg.Debug && len(speedSet) == 0 {
 		utils.Yellow.Println("[调试] 没有满足 下载速度下限 条件的 IP，忽略条件返回所有测速数据。")
 		speedSet = utils.DownloadSpeedSet(ipSet)
 	}
@@ -106,13 +117,109 @@ func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSe
 	return
 }
 
+// recordSpeed 记录单个 IP 的下载测速结果，返回是否满足速度下限
+func (s *Scanner) recordSpeed(data *utils.CloudflareIPData, result downloadResult) bool {
+	data.DownloadSpeed = result.speed
+	data.Downloaded = true
+	data.SingleSpeed = result.single
+	if data.Colo == "" {
+		data.Colo = result.colo
+	}
+	if result.proto != "" {
+		data.Proto = result.proto
+	}
+	data.Rejected.Merge(result.rejected)
+	return result.speed >= s.cfg.MinSpeed*1024*1024
+}
+
+// testDownloadConcurrent 并发测试下载速度，最多同时测试 DownloadRoutines 个 IP
+// 首个 IP 单独测速作为带宽基准；并发测速的总带宽未明显高于单独测速时，说明上行带宽已跑满、相互干扰，
+// 此时回退为逐个测速，并重新测速受干扰的 IP
+func (s *Scanner) testDownloadConcurrent(ctx context.Context, ipSet utils.PingDelaySet, testCount int, bar *utils.Bar) (speedSet utils.DownloadSpeedSet) {
+	dctx, cancel := context.WithCancel(ctx) // 凑够数量后中止进行中的测速
+	defer cancel()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		cond    = sync.NewCond(&mu)
+		meter   = &bandwidthMeter{}
+		sched   = &downloadScheduler{routines: s.cfg.DownloadRoutines}
+		running int   // 进行中的测速数量
+		next    int   // 下一个待测速的 IP
+		retry   []int // 受干扰需要重新测速的 IP
+		enough  bool  // 是否已凑够数量
+	)
+
+	test := func(i int) {
+		defer wg.Done()
+		own := &bandwidthMeter{parent: meter}
+		start, before := time.Now(), meter.total()
+		result := s.downloadHandler(dctx, ipSet[i].IP, own)
+		elapsed, total := time.Since(start), meter.total()-before
+
+		mu.Lock()
+		defer mu.Unlock()
+		defer cond.Broadcast()
+		running--
+		if dctx.Err() != nil || enough { // 测速被中断，结果不完整，直接丢弃
+			return
+		}
+		wasSerial := sched.serial
+		if sched.observe(own.total(), total, elapsed) {
+			if !wasSerial {
+				utils.Yellow.Printf("\n[信息] 并发下载测速总带宽 %.2f MB/s 未明显高于单独测速的 %.2f MB/s，上行带宽可能已跑满，回退为逐个测速...\n",
+					float64(total)/elapsed.Seconds()/1024/1024, sched.solo/1024/1024)
+			}
+			retry = append(retry, i)
+			return
+		}
+		if s.recordSpeed(&ipSet[i], result) {
+			bar.Grow(1, "")
+			speedSet = append(speedSet, ipSet[i])
+			if len(speedSet) == testCount {
+				enough = true
+				cancel()
+			}
+		}
+	}
+
+	mu.Lock()
+	for {
+		// 等待空闲的并发名额，或等待进行中的测速产生需要重新测速的 IP
+		for running > 0 && !enough && (running >= sched.limit() || len(retry) == 0 && next >= len(ipSet)) {
+			cond.Wait()
+		}
+		if enough || ctx.Err() != nil {
+			break
+		}
+		var i int
+		if len(retry) > 0 {
+			i, retry = retry[0], retry[1:]
+		} else if next < len(ipSet) {
+			i, next = next, next+1
+		} else {
+			break
+		}
+		running++
+		wg.Add(1)
+		go test(i)
+	}
+	mu.Unlock()
+	wg.Wait()
+
+	if sched.peak > 0 {
+		utils.Cyan.Printf("[信息] 下载测速峰值总带宽：%.2f MB/s\n", sched.peak/1024/1024)
+	}
+	return
+}
+
 // getDialContext 创建自定义拨号上下文，使用指定 IP
-func getDialContext(ip *net.IPAddr) func(ctx context.Context, network, address string) (net.Conn, error) {
+func getDialContext(ip *net.IPAddr, port int) func(ctx context.Context, network, address string) (net.Conn, error) {
 	var fakeSourceAddr string
 	if isIPv4(ip.String()) {
-		fakeSourceAddr = fmt.Sprintf("%s:%d", ip.String(), TCPPort)
+		fakeSourceAddr = fmt.Sprintf("%s:%d", ip.String(), port)
 	} else {
-		fakeSourceAddr = fmt.Sprintf("[%s]:%d", ip.String(), TCPPort)
+		fakeSourceAddr = fmt.Sprintf("[%s]:%d", ip.String(), port)
 	}
 	return func(ctx context.Context, network, address string) (net.Conn, error) {
 		return (&net.Dialer{}).DialContext(ctx, network, fakeSourceAddr)
@@ -142,17 +249,28 @@ func printDownloadDebugInfo(ip *net.IPAddr, err error, statusCode int, url, last
 	}
 }
 
-// downloadHandler 执行单个 IP 的下载测速
-// 返回值：下载速度、地区码
-func downloadHandler(ip *net.IPAddr) (float64, string) {
+// downloadResult 单个 IP 的下载测速结果
+type downloadResult struct {
+	speed    float64           // 下载速度（多连接时为各连接的总速度）
+	single   float64           // 单连接下载速度（多连接时为最快的连接在与其他连接共享带宽时的速度）
+	colo     string            // 地区码
+	proto    string            // 使用的 HTTP 协议版本
+	rejected utils.StatusTally // 被拒绝的 HTTP 状态码及次数
+}
+
+// openDownload 建立一个新连接并发起下载测速请求，失败时返回 nil
+// HTTP 状态码无效时同时返回该状态码
+func (s *Scanner) openDownload(ctx context.Context, ip *net.IPAddr) (*http.Response, int) {
 	var lastRedirectURL string // 记录最后一次重定向目标
+	transport := s.cfg.newTransport(ip)
 	client := &http.Client{
-		Transport: &http.Transport{DialContext: getDialContext(ip)},
-		Timeout:   Timeout,
+		Transport: transport,
+		Timeout:   s.cfg.Timeout,
+		Jar:       s.cfg.newCookieJar(),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			lastRedirectURL = req.URL.String()
 			if len(via) > 10 { // 限制最多重定向 10 次
-				if utils.Debug {
+				if s.cfg.Debug {
 					utils.Red.Printf("[调试] IP: %s, 下载测速地址重定向次数过多，终止测速，下载测速地址: %s\n", ip.String(), req.URL.String())
 				}
 				return http.ErrUseLastResponse
@@ -163,43 +281,68 @@ func downloadHandler(ip *net.IPAddr) (float64, string) {
 			return nil
 		},
 	}
-	req, err := http.NewRequest("GET", URL, nil)
+	req, err := s.cfg.newRequest(ctx, s.cfg.DownloadMethod, s.cfg.URL, s.cfg.requestBody())
 	if err != nil {
-		if utils.Debug {
-			utils.Red.Printf("[调试] IP: %s, 下载测速请求创建失败，错误信息: %v, 下载测速地址: %s\n", ip.String(), err, URL)
+		if s.cfg.Debug {
+			utils.Red.Printf("[调试] IP: %s, 下载测速请求创建失败，错误信息: %v, 下载测速地址: %s\n", ip.String(), err, s.cfg.URL)
 		}
-		return 0.0, ""
+		return nil, 0
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
-
 	response, err := client.Do(req)
 	if err != nil {
-		if utils.Debug {
-			printDownloadDebugInfo(ip, err, 0, URL, lastRedirectURL, response)
+		if s.cfg.Debug {
+			printDownloadDebugInfo(ip, err, 0, s.cfg.URL, lastRedirectURL, response)
 		}
-		return 0.0, ""
+		closeTransport(transport)
+		return nil, 0
 	}
-	defer response.Body.Close()
-	if response.StatusCode != 200 {
-		if utils.Debug {
-			printDownloadDebugInfo(ip, nil, response.StatusCode, URL, lastRedirectURL, response)
+	response.Body = &transportBody{ReadCloser: response.Body, transport: transport} // 下载完毕关闭 Body 时释放连接
+	if !s.cfg.DownloadStatusCodes.Match(response.StatusCode) {
+		if s.cfg.Debug {
+			printDownloadDebugInfo(ip, nil, response.StatusCode, s.cfg.URL, lastRedirectURL, response)
 		}
-		return 0.0, ""
+		response.Body.Close()
+		return nil, response.StatusCode
 	}
+	if !s.cfg.checkProtocol(response) {
+		if s.cfg.Debug {
+			utils.Red.Printf("[调试] IP: %s, 下载测速终止，HTTP 协议版本: %s, 指定的 HTTP 协议版本: %s, 下载测速地址: %s\n", ip.String(), response.Proto, s.cfg.Protocol, s.cfg.URL)
+		}
+		response.Body.Close()
+		return nil, 0
+	}
+	return response, 0
+}
+
+// downloadHandler 执行单个 IP 的下载测速，指定了多个连接时交给 downloadMulti
+// meter 不为空时同时记录下载量，用于统计并发测速的总带宽
+func (s *Scanner) downloadHandler(ctx context.Context, ip *net.IPAddr, meter *bandwidthMeter) downloadResult {
+	if s.cfg.DownloadConns > 1 {
+		return s.downloadMulti(ctx, ip, meter)
+	}
+	response, code := s.openDownload(ctx, ip)
+	if response == nil {
+		var result downloadResult
+		if code != 0 {
+			result.rejected.Add(code)
+		}
+		return result
+	}
+	defer response.Body.Close()
 
 	// 从响应头获取地区码
 	colo := getHeaderColo(response.Header)
 
-	timeStart := time.Now()           // 开始时间
-	timeEnd := timeStart.Add(Timeout) // 结束时间
+	timeStart := time.Now()                 // 开始时间
+	timeEnd := timeStart.Add(s.cfg.Timeout) // 结束时间
 
 	contentLength := response.ContentLength // 文件大小
 	buffer := make([]byte, bufferSize)
 
 	var (
 		contentRead     int64 = 0
-		timeSlice             = Timeout / 100
+		timeSlice             = s.cfg.Timeout / 100
 		timeCounter           = 1
 		lastContentRead int64 = 0
 	)
@@ -232,7 +375,109 @@ func downloadHandler(ip *net.IPAddr) (float64, string) {
 			e.Add(float64(contentRead-lastContentRead) / (float64(currentTime.Sub(last_time_slice)) / float64(timeSlice)))
 		}
 		contentRead += int64(bufferRead)
+		meter.add(bufferRead)
 	}
 	// 返回下载速度（MB/s）
-	return e.Value() / (Timeout.Seconds() / 120), colo
+	speed := e.Value() / (s.cfg.Timeout.Seconds() / 120)
+	return downloadResult{speed: speed, single: speed, colo: colo, proto: response.Proto}
+}
+
+// downloadMulti 对单个 IP 同时建立 DownloadConns 个连接下载测速，各连接的下载量合并计入 EWMA 计算总速度
+// 单连接速度按最快的连接在总下载量中的占比折算，即各连接竞争带宽时最快的连接分得的速度，并非单独使用一个连接时的速度
+func (s *Scanner) downloadMulti(ctx context.Context, ip *net.IPAddr, meter *bandwidthMeter) downloadResult {
+	responses := make([]*http.Response, s.cfg.DownloadConns)
+	codes := make([]int, s.cfg.DownloadConns)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], codes[i] = s.openDownload(ctx, ip)
+		}(i)
+	}
+	wg.Wait()
+	var rejected utils.StatusTally
+	for _, code := range codes {
+		if code != 0 {
+			rejected.Add(code)
+		}
+	}
+
+	var colo, proto string
+	total := &bandwidthMeter{parent: meter}
+	conns := make([]*bandwidthMeter, 0, len(responses))
+	done := make(chan struct{})
+	for _, response := range responses {
+		if response == nil { // 建立失败的连接不计入
+			continue
+		}
+		defer response.Body.Close()
+		if colo == "" {
+			colo = getHeaderColo(response.Header) // 从响应头获取地区码
+		}
+		proto = response.Proto
+		conn := &bandwidthMeter{parent: total}
+		conns = append(conns, conn)
+		wg.Add(1)
+		go func(body io.Reader) {
+			defer wg.Done()
+			buffer := make([]byte, bufferSize)
+			for {
+				n, err := body.Read(buffer)
+				conn.add(n)
+				if err != nil {
+					return
+				}
+			}
+		}(response.Body)
+	}
+	if len(conns) == 0 {
+		return downloadResult{rejected: rejected}
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timeSlice := s.cfg.Timeout / 100
+	ticker := time.NewTicker(timeSlice)
+	defer ticker.Stop()
+	timer := time.NewTimer(s.cfg.Timeout)
+	defer timer.Stop()
+	e := ewma.NewMovingAverage() // 使用 EWMA 算法计算平均速度
+	var lastContentRead int64
+	lastTick := time.Now()
+
+	// 每个时间片统计一次所有连接的下载量
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			contentRead := total.total()
+			e.Add(float64(contentRead - lastContentRead))
+			lastContentRead, lastTick = contentRead, time.Now()
+		case <-done: // 所有连接均下载完成，计算最后一个时间片的速度
+			if elapsed := time.Since(lastTick); elapsed > 0 {
+				e.Add(float64(total.total()-lastContentRead) / (float64(elapsed) / float64(timeSlice)))
+			}
+			break loop
+		case <-timer.C: // 超时则退出
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	speed := e.Value() / (s.cfg.Timeout.Seconds() / 120)
+	result := downloadResult{speed: speed, colo: colo, proto: proto, rejected: rejected}
+	if sum := total.total(); sum > 0 {
+		var fastest int64
+		for _, conn := range conns {
+			if n := conn.total(); n > fastest {
+				fastest = n
+			}
+		}
+		result.single = speed * float64(fastest) / float64(sum)
+	}
+	return result
 }
@@ -1,30 +1,25 @@
 package task
 
 import (
+	"context"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
 )
 
 // TestCheckDownloadDefault 测试下载参数默认校验功能
 // 验证参数越界时能正确重置为默认值
 func TestCheckDownloadDefault(t *testing.T) {
-	// 保存原始值
-	originalURL := URL
-	originalTimeout := Timeout
-	originalTestCount := TestCount
-	originalMinSpeed := MinSpeed
-
-	defer func() {
-		URL = originalURL
-		Timeout = originalTimeout
-		TestCount = originalTestCount
-		MinSpeed = originalMinSpeed
-	}()
-
 	tests := []struct {
 		name      string
-		setup     func()
+		setup     func(c *Config)
 		wantURL   string
 		wantTime  time.Duration
 		wantCount int
@@ -32,11 +27,11 @@ func TestCheckDownloadDefault(t *testing.T) {
 	}{
 		{
 			name: "有效值",
-			setup: func() {
-				URL = "https://test.com"
-				Timeout = 5 * time.Second
-				TestCount = 5
-				MinSpeed = 1.0
+			setup: func(c *Config) {
+				c.URL = "https://test.com"
+				c.Timeout = 5 * time.Second
+				c.TestCount = 5
+				c.MinSpeed = 1.0
 			},
 			wantURL:   "https://test.com",
 			wantTime:  5 * time.Second,
@@ -45,74 +40,75 @@ func TestCheckDownloadDefault(t *testing.T) {
 		},
 		{
 			name: "空 URL 重置为默认",
-			setup: func() {
-				URL = ""
-				Timeout = 10 * time.Second
-				TestCount = 10
-				MinSpeed = 0.0
+			setup: func(c *Config) {
+				c.URL = ""
+				c.Timeout = 10 * time.Second
+				c.TestCount = 10
+				c.MinSpeed = 0.0
 			},
-			wantURL:    defaultURL,
-			wantTime:   10 * time.Second,
-			wantCount:  10,
-			wantSpeed:  defaultMinSpeed,
+			wantURL:   defaultURL,
+			wantTime:  10 * time.Second,
+			wantCount: 10,
+			wantSpeed: defaultMinSpeed,
 		},
 		{
 			name: "零超时重置为默认",
-			setup: func() {
-				URL = "https://test.com"
-				Timeout = 0
-				TestCount = 10
-				MinSpeed = 0.0
+			setup: func(c *Config) {
+				c.URL = "https://test.com"
+				c.Timeout = 0
+				c.TestCount = 10
+				c.MinSpeed = 0.0
 			},
-			wantURL:    "https://test.com",
-			wantTime:   defaultTimeout,
-			wantCount:  10,
-			wantSpeed:  defaultMinSpeed,
+			wantURL:   "https://test.com",
+			wantTime:  defaultTimeout,
+			wantCount: 10,
+			wantSpeed: defaultMinSpeed,
 		},
 		{
 			name: "零测试数量重置为默认",
-			setup: func() {
-				URL = "https://test.com"
-				Timeout = 10 * time.Second
-				TestCount = 0
-				MinSpeed = 0.0
+			setup: func(c *Config) {
+				c.URL = "https://test.com"
+				c.Timeout = 10 * time.Second
+				c.TestCount = 0
+				c.MinSpeed = 0.0
 			},
-			wantURL:    "https://test.com",
-			wantTime:   10 * time.Second,
-			wantCount:  defaultTestNum,
-			wantSpeed:  defaultMinSpeed,
+			wantURL:   "https://test.com",
+			wantTime:  10 * time.Second,
+			wantCount: defaultTestNum,
+			wantSpeed: defaultMinSpeed,
 		},
 		{
 			name: "负最小速度重置为默认",
-			setup: func() {
-				URL = "https://test.com"
-				Timeout = 10 * time.Second
-				TestCount = 10
-				MinSpeed = -1.0
+			setup: func(c *Config) {
+				c.URL = "https://test.com"
+				c.Timeout = 10 * time.Second
+				c.TestCount = 10
+				c.MinSpeed = -1.0
 			},
-			wantURL:    "https://test.com",
-			wantTime:   10 * time.Second,
-			wantCount:  10,
-			wantSpeed:  defaultMinSpeed,
+			wantURL:   "https://test.com",
+			wantTime:  10 * time.Second,
+			wantCount: 10,
+			wantSpeed: defaultMinSpeed,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.setup()
-			checkDownloadDefault()
+			var c Config
+			tt.setup(&c)
+			c.checkDownloadDefault()
 
-			if URL != tt.wantURL {
-				t.Errorf("URL = %s, expected %s", URL, tt.wantURL)
+			if c.URL != tt.wantURL {
+				t.Errorf("URL = %s, expected %s", c.URL, tt.wantURL)
 			}
-			if Timeout != tt.wantTime {
-				t.Errorf("Timeout = %v, expected %v", Timeout, tt.wantTime)
+			if c.Timeout != tt.wantTime {
+				t.Errorf("Timeout = %v, expected %v", c.Timeout, tt.wantTime)
 			}
-			if TestCount != tt.wantCount {
-				t.Errorf("TestCount = %d, expected %d", TestCount, tt.wantCount)
+			if c.TestCount != tt.wantCount {
+				t.Errorf("TestCount = %d, expected %d", c.TestCount, tt.wantCount)
 			}
-			if MinSpeed != tt.wantSpeed {
-				t.Errorf("MinSpeed = %f, expected %f", MinSpeed, tt.wantSpeed)
+			if c.MinSpeed != tt.wantSpeed {
+				t.Errorf("MinSpeed = %f, expected %f", c.MinSpeed, tt.wantSpeed)
 			}
 		})
 	}
@@ -121,9 +117,7 @@ func TestCheckDownloadDefault(t *testing.T) {
 // TestGetDialContext_IPv4 测试 IPv4 拨号上下文创建
 func TestGetDialContext_IPv4(t *testing.T) {
 	ip := net.ParseIP("1.1.1.1")
-	TCPPort = 443
-
-	dialCtx := getDialContext(&net.IPAddr{IP: ip})
+	dialCtx := getDialContext(&net.IPAddr{IP: ip}, 443)
 
 	if dialCtx == nil {
 		t.Fatal("getDialContext returned nil")
@@ -133,9 +127,7 @@ func TestGetDialContext_IPv4(t *testing.T) {
 // TestGetDialContext_IPv6 测试 IPv6 拨号上下文创建
 func TestGetDialContext_IPv6(t *testing.T) {
 	ip := net.ParseIP("2606:4700::")
-	TCPPort = 443
-
-	dialCtx := getDialContext(&net.IPAddr{IP: ip})
+	dialCtx := getDialContext(&net.IPAddr{IP: ip}, 443)
 
 	if dialCtx == nil {
 		t.Fatal("getDialContext returned nil")
@@ -152,3 +144,72 @@ func TestPrintDownloadDebugInfo(t *testing.T) {
 	printDownloadDebugInfo(&net.IPAddr{IP: ip}, err, 0, "https://test.com", "", nil)
 	printDownloadDebugInfo(&net.IPAddr{IP: ip}, err, 403, "https://test.com", "", nil)
 }
+
+// TestTestDownloadSpeed_Canceled 测试上下文取消后跳过下载测速并返回已有数据
+func TestTestDownloadSpeed_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ipSet := utils.PingDelaySet{
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4}},
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("2.2.2.2")}, Sended: 4, Received: 4}},
+	}
+
+	speedSet := NewScanner(DefaultConfig()).TestDownloadSpeed(ctx, ipSet)
+
+	if len(speedSet) != 2 {
+		t.Errorf("expected 2 results, got %d", len(speedSet))
+	}
+	for _, v := range speedSet {
+		if v.DownloadSpeed != 0 {
+			t.Errorf("IP %s DownloadSpeed = %f, expected 0", v.IP.String(), v.DownloadSpeed)
+		}
+	}
+}
+
+// downloadServer 启动本地下载测速服务器，返回指向该服务器的配置及请求计数
+// 所有 IP 均使用 127.0.0.1，拨号时固定连接到该服务器
+func downloadServer(t *testing.T, size int) (Config, *int32) {
+	t.Helper()
+	body := make([]byte, size)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("Server", "cloudflare")
+		w.Header().Set("Cf-Ray", "7bd32409eda7b020-SJC")
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.URL = srv.URL
+	cfg.TCPPort = port
+	cfg.Timeout = time.Second
+	return cfg, &requests
+}
+
+// TestDownloadHandler_MultiConn 测试单个 IP 多连接下载测速
+func TestDownloadHandler_MultiConn(t *testing.T) {
+	cfg, requests := downloadServer(t, 4*1024*1024)
+	cfg.DownloadConns = 3
+	s := NewScanner(cfg)
+
+	meter := &bandwidthMeter{}
+	result := s.downloadHandler(context.Background(), &net.IPAddr{IP: net.ParseIP("127.0.0.1")}, meter)
+
+	if n := atomic.LoadInt32(requests); n != 3 {
+		t.Errorf("requests = %d, expected 3", n)
+	}
+	if meter.total() != 3*4*1024*1024 {
+		t.Errorf("meter total = %d, expected %d", meter.total(), 3*4*1024*1024)
+	}
+	if result.speed <= 0 || result.single <= 0 || result.single > result.speed {
+		t.Errorf("speed = %f, single = %f, expected 0 < single <= speed", result.speed, result.single)
+	}
+	if result.colo != "SJC" {
+		t.Errorf("colo = %q, expected SJC", result.colo)
+	}
+}
@@ -1,11 +1,7 @@
 package task
 
 import (
-	//"crypto/tls"
-
-	"io"
-	"log"
-	"net"
+	"context"
 	"net/http"
 	"regexp"
 	"strings"
@@ -16,97 +12,99 @@ import (
 )
 
 var (
-	Httping               bool
-	HttpingStatusCode     int
-	HttpingCFColo         string
-	HttpingCFColomap      *sync.Map
 	RegexpColoIATACode    = regexp.MustCompile(`[A-Z]{3}`)  // 匹配 IATA 机场地区码（俗称 机场三字码）的正则表达式
 	RegexpColoCountryCode = regexp.MustCompile(`[A-Z]{2}`)  // 匹配国家地区码的正则表达式（如 US、CN、UK 等）
 	RegexpColoGcore       = regexp.MustCompile(`^[a-z]{2}`) // 匹配城市地区码的正则表达式（小写，如 us、cn、uk 等）
 )
 
 // httping 执行 HTTP 延迟测试
-// 返回值：成功次数、总延迟、地区码
-func (p *Ping) httping(ip *net.IPAddr) (int, time.Duration, string) {
+// 将每次成功测速的延迟、地区码、使用的 HTTP 协议版本及被拒绝的 HTTP 状态码记录到 data 中
+func (p *Ping) httping(ctx context.Context, data *utils.PingData) {
+	ip := data.IP
 	// 创建 HTTP 客户端
+	transport := p.cfg.newTransport(ip)
+	defer closeTransport(transport)
 	hc := http.Client{
-		Timeout: time.Second * 2,
-		Transport: &http.Transport{
-			DialContext: getDialContext(ip),
-			//TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // 跳过证书验证
-		},
+		Timeout:   time.Second * 2,
+		Transport: transport,
+		Jar:       p.cfg.newCookieJar(),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse // 阻止重定向
 		},
 	}
 
-	// 先访问一次获得 HTTP 状态码及地区码
-	var colo string
+	// 先访问一次获得 HTTP 状态码、协议版本及地区码
+	var colo, proto string
 	{
-		request, err := http.NewRequest(http.MethodHead, URL, nil)
+		request, err := p.cfg.newRequest(ctx, p.cfg.HttpingMethod, p.cfg.URL, p.cfg.requestBody())
 		if err != nil {
-			if utils.Debug {
-				utils.Red.Printf("[调试] IP: %s, 延迟测速请求创建失败，错误信息: %v, 测速地址: %s\n", ip.String(), err, URL)
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 延迟测速请求创建失败，错误信息: %v, 测速地址: %s\n", ip.String(), err, p.cfg.URL)
 			}
-			return 0, 0, ""
+			return
 		}
-		request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
 		response, err := hc.Do(request)
 		if err != nil {
-			if utils.Debug {
-				utils.Red.Printf("[调试] IP: %s, 延迟测速失败，错误信息: %v, 测速地址: %s\n", ip.String(), err, URL)
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 延迟测速失败，错误信息: %v, 测速地址: %s\n", ip.String(), err, p.cfg.URL)
 			}
-			return 0, 0, ""
+			return
 		}
 		defer response.Body.Close()
 
 		// 检查 HTTP 状态码
-		if HttpingStatusCode == 0 || HttpingStatusCode < 100 && HttpingStatusCode > 599 {
-			// 未指定状态码时，默认接受 200、301、302
-			if response.StatusCode != 200 && response.StatusCode != 301 && response.StatusCode != 302 {
-				if utils.Debug {
-					utils.Red.Printf("[调试] IP: %s, 延迟测速终止，HTTP 状态码: %d, 测速地址: %s\n", ip.String(), response.StatusCode, URL)
-				}
-				return 0, 0, ""
+		if !p.cfg.HttpingStatusCodes.Match(response.StatusCode) {
+			data.Rejected.Add(response.StatusCode)
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 延迟测速终止，HTTP 状态码: %d, 有效的 HTTP 状态码: %s, 测速地址: %s\n", ip.String(), response.StatusCode, p.cfg.HttpingStatusCodes, p.cfg.URL)
 			}
-		} else {
-			if response.StatusCode != HttpingStatusCode {
-				if utils.Debug {
-					utils.Red.Printf("[调试] IP: %s, 延迟测速终止，HTTP 状态码: %d, 指定的 HTTP 状态码 %d, 测速地址: %s\n", ip.String(), response.StatusCode, HttpingStatusCode, URL)
-				}
-				return 0, 0, ""
+			return
+		}
+
+		// 检查 HTTP 协议版本
+		if !p.cfg.checkProtocol(response) {
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 延迟测速终止，HTTP 协议版本: %s, 指定的 HTTP 协议版本: %s, 测速地址: %s\n", ip.String(), response.Proto, p.cfg.Protocol, p.cfg.URL)
 			}
+			return
 		}
 
-		io.Copy(io.Discard, response.Body)
+		// 校验响应内容
+		if err = p.cfg.HttpingCheck.check(response); err != nil {
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 延迟测速终止，响应内容校验失败: %v, 测速地址: %s\n", ip.String(), err, p.cfg.URL)
+			}
+			return
+		}
 
 		// 通过响应头获取地区码
 		colo = getHeaderColo(response.Header)
+		proto = response.Proto
 
 		// 如果指定了地区筛选，则匹配地区码
-		if HttpingCFColo != "" {
+		if p.cfg.HttpingCFColo != "" {
 			colo = p.filterColo(colo)
 			if colo == "" {
-				if utils.Debug {
+				if p.cfg.Debug {
 					utils.Red.Printf("[调试] IP: %s, 地区码不匹配: %s\n", ip.String(), colo)
 				}
-				return 0, 0, ""
+				return
 			}
 		}
 	}
 
 	// 循环测速计算延迟
-	success := 0
-	var delay time.Duration
-	for i := 0; i < PingTimes; i++ {
-		request, err := http.NewRequest(http.MethodHead, URL, nil)
-		if err != nil {
-			log.Fatal("意外的错误，情报告：", err)
-			return 0, 0, ""
+	var delays []time.Duration
+	for i := 0; i < p.cfg.PingTimes; i++ {
+		request, err := p.cfg.newRequest(ctx, p.cfg.HttpingMethod, p.cfg.URL, p.cfg.requestBody())
+		if err != nil { // 视为本次测速失败（丢包）
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 延迟测速请求创建失败，错误信息: %v, 测速地址: %s\n", ip.String(), err, p.cfg.URL)
+			}
+			continue
 		}
-		request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
 		// 最后一次请求关闭连接
-		if i == PingTimes-1 {
+		if i == p.cfg.PingTimes-1 {
 			request.Header.Set("Connection", "close")
 		}
 		startTime := time.Now()
@@ -114,23 +112,35 @@ func (p *Ping) httping(ip *net.IPAddr) (int, time.Duration, string) {
 		if err != nil {
 			continue
 		}
-		success++
-		io.Copy(io.Discard, response.Body)
+		if !p.cfg.HttpingStatusCodes.Match(response.StatusCode) { // 状态码无效的请求视为失败
+			data.Rejected.Add(response.StatusCode)
+			_ = response.Body.Close()
+			continue
+		}
+		err = p.cfg.HttpingCheck.check(response) // 未通过校验的请求视为失败
 		_ = response.Body.Close()
-		duration := time.Since(startTime)
-		delay += duration
+		if err != nil {
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 响应内容校验失败: %v, 测速地址: %s\n", ip.String(), err, p.cfg.URL)
+			}
+			continue
+		}
+		delays = append(delays, time.Since(startTime))
+	}
+	if len(data.Rejected) > 0 && p.cfg.Debug {
+		utils.Red.Printf("[调试] IP: %s, 被拒绝的 HTTP 状态码: %s, 测速地址: %s\n", ip.String(), data.Rejected, p.cfg.URL)
 	}
 
-	return success, delay, colo
+	data.Samples, data.Colo, data.Proto = delays, colo, proto
 }
 
 // MapColoMap 创建地区码筛选映射表
-func MapColoMap() *sync.Map {
-	if HttpingCFColo == "" {
+func MapColoMap(cfColo string) *sync.Map {
+	if cfColo == "" {
 		return nil
 	}
 	// 将 -cfcolo 参数指定的地区码转为大写并格式化
-	colos := strings.Split(strings.ToUpper(HttpingCFColo), ",")
+	colos := strings.Split(strings.ToUpper(cfColo), ",")
 	colomap := &sync.Map{}
 	for _, colo := range colos {
 		colomap.Store(colo, colo)
@@ -199,11 +209,11 @@ func (p *Ping) filterColo(colo string) string {
 		return ""
 	}
 	// 如果没有指定 -cfcolo 参数，则直接返回
-	if HttpingCFColomap == nil {
+	if p.colomap == nil {
 		return colo
 	}
 	// 匹配 机场地区码 是否为指定的地区
-	_, ok := HttpingCFColomap.Load(colo)
+	_, ok := p.colomap.Load(colo)
 	if ok {
 		return colo
 	}
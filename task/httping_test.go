@@ -9,9 +9,6 @@ import (
 // TestMapColoMap 测试地区码映射创建功能
 // 将 -cfcolo 参数转换为 sync.Map 用于快速查找
 func TestMapColoMap(t *testing.T) {
-	original := HttpingCFColo
-	defer func() { HttpingCFColo = original }()
-
 	tests := []struct {
 		name      string
 		input     string
@@ -26,8 +23,7 @@ func TestMapColoMap(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			HttpingCFColo = tt.input
-			result := MapColoMap()
+			result := MapColoMap(tt.input)
 
 			if tt.wantLen == 0 {
 				if result != nil {
@@ -171,12 +167,8 @@ func TestGetHeaderColo_Empty(t *testing.T) {
 }
 
 // TestFilterColo_NoFilter 测试未设置地区过滤
-// 当 HttpingCFColomap 为 nil 时，返回原地区码
+// 当 colomap 为 nil 时，返回原地区码
 func TestFilterColo_NoFilter(t *testing.T) {
-	original := HttpingCFColomap
-	HttpingCFColomap = nil
-	defer func() { HttpingCFColomap = original }()
-
 	colo := "LAX"
 	result := (&Ping{}).filterColo(colo)
 
@@ -188,10 +180,9 @@ func TestFilterColo_NoFilter(t *testing.T) {
 // TestFilterColo_WithFilter 测试地区过滤功能
 // 只返回匹配指定地区的 IP
 func TestFilterColo_WithFilter(t *testing.T) {
-	HttpingCFColomap = &sync.Map{}
-	HttpingCFColomap.Store("LAX", "LAX")
-	HttpingCFColomap.Store("SEA", "SEA")
-	defer func() { HttpingCFColomap = nil }()
+	colomap := &sync.Map{}
+	colomap.Store("LAX", "LAX")
+	colomap.Store("SEA", "SEA")
 
 	tests := []struct {
 		name     string
@@ -205,7 +196,7 @@ func TestFilterColo_WithFilter(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := (&Ping{}).filterColo(tt.input)
+			result := (&Ping{colomap: colomap}).filterColo(tt.input)
 			if result != tt.expected {
 				t.Errorf("filterColo(%s) = %s, expected %s", tt.input, result, tt.expected)
 			}
@@ -2,7 +2,7 @@ package task
 
 import (
 	"bufio"
-	"log"
+	"fmt"
 	"math/rand"
 	"net"
 	"os"
@@ -13,13 +13,6 @@ import (
 
 const defaultInputFile = "ip.txt"
 
-// 全局变量
-var (
-	TestAll = false // 是否测试所有 IP（而非随机采样）
-	IPFile  = defaultInputFile // IP 段数据文件名
-	IPText  string // 直接通过参数指定的 IP 段数据
-)
-
 func InitRandSeed() {
 	rand.Seed(time.Now().UnixNano()) // 使用当前时间戳作为随机数种子
 }
@@ -42,6 +35,7 @@ type IPRanges struct {
 	mask    string        // 子网掩码
 	firstIP net.IP        // 起始 IP
 	ipNet   *net.IPNet    // IP 网络段
+	testAll bool          // 是否测试所有 IP（而非随机采样）
 }
 
 // 创建新的 IPRanges 实例
@@ -68,11 +62,12 @@ func (r *IPRanges) fixIP(ip string) string {
 }
 
 // 解析 IP 段 (CIDR 格式)，获得 IP、IP 范围、子网掩码
-func (r *IPRanges) parseCIDR(ip string) {
+func (r *IPRanges) parseCIDR(ip string) error {
 	var err error
 	if r.firstIP, r.ipNet, err = net.ParseCIDR(r.fixIP(ip)); err != nil {
-		log.Fatalln("ParseCIDR err", err)
+		return fmt.Errorf("IP 段[%s]格式有误：%w", ip, err)
 	}
+	return nil
 }
 
 // 添加 IPv4 地址到列表
@@ -110,7 +105,7 @@ func (r *IPRanges) chooseIPv4() {
 	} else {
 		minIP, hosts := r.getIPRange()    // 返回第四段 IP 的最小值及可用数目
 		for r.ipNet.Contains(r.firstIP) { // 只要该 IP 没有超出 IP 网段范围，就继续循环随机
-			if TestAll { // 如果是测速全部 IP
+			if r.testAll { // 如果是测速全部 IP
 				for i := 0; i <= int(hosts); i++ { // 遍历 IP 最后一段最小值到最大值
 					r.appendIPv4(byte(i) + minIP)
 				}
@@ -155,29 +150,33 @@ func (r *IPRanges) chooseIPv6() {
 }
 
 // 加载 IP 段数据，从文件或参数中获取
-func loadIPRanges() []*net.IPAddr {
+func loadIPRanges(cfg *Config) ([]*net.IPAddr, error) {
 	ranges := newIPRanges()
-	if IPText != "" { // 从参数中获取 IP 段数据
-		IPs := strings.Split(IPText, ",") // 以逗号分隔为数组并循环遍历
+	ranges.testAll = cfg.TestAll
+	if cfg.IPText != "" { // 从参数中获取 IP 段数据
+		IPs := strings.Split(cfg.IPText, ",") // 以逗号分隔为数组并循环遍历
 		for _, IP := range IPs {
 			IP = strings.TrimSpace(IP) // 去除首尾的空白字符
 			if IP == "" {              // 跳过空的
 				continue
 			}
-			ranges.parseCIDR(IP) // 解析 IP 段
-			if isIPv4(IP) {      // 生成 IPv4 / IPv6 地址
+			if err := ranges.parseCIDR(IP); err != nil { // 解析 IP 段
+				return nil, err
+			}
+			if isIPv4(IP) { // 生成 IPv4 / IPv6 地址
 				ranges.chooseIPv4()
 			} else {
 				ranges.chooseIPv6()
 			}
 		}
 	} else { // 从文件中获取 IP 段数据
-		if IPFile == "" {
-			IPFile = defaultInputFile
+		ipFile := cfg.IPFile
+		if ipFile == "" {
+			ipFile = defaultInputFile
 		}
-		file, err := os.Open(IPFile)
+		file, err := os.Open(ipFile)
 		if err != nil {
-			log.Fatal(err)
+			return nil, fmt.Errorf("读取 IP 段数据文件[%s]失败：%w", ipFile, err)
 		}
 		defer file.Close()
 		scanner := bufio.NewScanner(file)
@@ -186,13 +185,18 @@ func loadIPRanges() []*net.IPAddr {
 			if line == "" {                           // 跳过空行
 				continue
 			}
-			ranges.parseCIDR(line) // 解析 IP 段
-			if isIPv4(line) {      // 生成 IPv4 / IPv6 地址
+			if err = ranges.parseCIDR(line); err != nil { // 解析 IP 段
+				return nil, err
+			}
+			if isIPv4(line) { // 生成 IPv4 / IPv6 地址
 				ranges.chooseIPv4()
 			} else {
 				ranges.chooseIPv6()
 			}
 		}
+		if err = scanner.Err(); err != nil {
+			return nil, fmt.Errorf("读取 IP 段数据文件[%s]失败：%w", ipFile, err)
+		}
 	}
-	return ranges.ips
+	return ranges.ips, nil
 }
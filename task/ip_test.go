@@ -1,7 +1,9 @@
 package task
 
 import (
+	"context"
 	"net"
+	"path/filepath"
 	"testing"
 )
 
@@ -116,16 +118,10 @@ func TestIPRanges_GetIPRange(t *testing.T) {
 
 // TestLoadIPRanges_SingleIP 测试加载单个 IP 功能
 func TestLoadIPRanges_SingleIP(t *testing.T) {
-	// 保存原始值
-	originalIPFile := IPFile
-	originalIPText := IPText
-	defer func() {
-		IPFile = originalIPFile
-		IPText = originalIPText
-	}()
-
-	IPText = "1.1.1.1"
-	ips := loadIPRanges()
+	ips, err := loadIPRanges(&Config{IPText: "1.1.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if len(ips) != 1 {
 		t.Errorf("expected 1 IP, got %d", len(ips))
@@ -137,16 +133,11 @@ func TestLoadIPRanges_SingleIP(t *testing.T) {
 
 // TestLoadIPRanges_CIDR 测试加载 CIDR 网段功能
 func TestLoadIPRanges_CIDR(t *testing.T) {
-	originalIPFile := IPFile
-	originalIPText := IPText
-	defer func() {
-		IPFile = originalIPFile
-		IPText = originalIPText
-	}()
-
 	// /30 网段包含 4 个 IP
-	IPText = "10.0.0.0/30"
-	ips := loadIPRanges()
+	ips, err := loadIPRanges(&Config{IPText: "10.0.0.0/30"})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// 不使用 TestAll 时，只随机获取 1 个 IP
 	if len(ips) != 1 {
@@ -156,15 +147,10 @@ func TestLoadIPRanges_CIDR(t *testing.T) {
 
 // TestLoadIPRanges_IPv6 测试加载 IPv6 地址功能
 func TestLoadIPRanges_IPv6(t *testing.T) {
-	originalIPFile := IPFile
-	originalIPText := IPText
-	defer func() {
-		IPFile = originalIPFile
-		IPText = originalIPText
-	}()
-
-	IPText = "2606:4700::/128"
-	ips := loadIPRanges()
+	ips, err := loadIPRanges(&Config{IPText: "2606:4700::/128"})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if len(ips) != 1 {
 		t.Errorf("expected 1 IPv6 IP, got %d", len(ips))
@@ -193,3 +179,16 @@ func TestInitRandSeed(t *testing.T) {
 	// 验证不 panic
 	InitRandSeed()
 }
+
+// TestLoadIPRanges_Invalid 测试 IP 段格式有误或文件无法读取时返回错误
+func TestLoadIPRanges_Invalid(t *testing.T) {
+	if _, err := loadIPRanges(&Config{IPText: "1.1.1.1,not-an-ip"}); err == nil {
+		t.Error("expected error for invalid IP range")
+	}
+	if _, err := loadIPRanges(&Config{IPFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("expected error for missing IP file")
+	}
+	if _, err := NewScanner(Config{IPText: "300.0.0.1"}).Run(context.Background()); err == nil {
+		t.Error("expected Run to return the IP range error")
+	}
+}
@@ -0,0 +1,116 @@
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// HTTPing、下载及上传测速使用的 HTTP 协议版本
+const (
+	ProtocolHTTP1 = "h1" // HTTP/1.1（默认）
+	ProtocolHTTP2 = "h2" // 强制 HTTP/2（需 HTTPS 测速地址）
+	ProtocolHTTP3 = "h3" // HTTP/3 (QUIC)，通过 UDP 连接到测速端口（需 HTTPS 测速地址）
+)
+
+// ParseProtocol 解析 HTTP 协议版本，为空时为 HTTP/1.1
+func ParseProtocol(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", ProtocolHTTP1, "http1", "http/1.1":
+		return ProtocolHTTP1, nil
+	case ProtocolHTTP2, "http2", "http/2":
+		return ProtocolHTTP2, nil
+	case ProtocolHTTP3, "http3", "http/3", "quic":
+		return ProtocolHTTP3, nil
+	}
+	return "", fmt.Errorf("未知的 HTTP 协议版本 %q，可选 %s、%s、%s", s, ProtocolHTTP1, ProtocolHTTP2, ProtocolHTTP3)
+}
+
+// checkProtocolDefault 检查并修正 HTTP 协议版本
+func (c *Config) checkProtocolDefault() {
+	if c.Protocol != ProtocolHTTP2 && c.Protocol != ProtocolHTTP3 {
+		c.Protocol = ProtocolHTTP1
+	}
+}
+
+// serverName 返回 TLS 握手使用的 SNI，为空时由调用方使用测速地址的域名
+// 未指定 SNI 但指定了 Host 时，SNI 与 Host 保持一致
+func (c *Config) serverName() string {
+	if c.SNI != "" {
+		return c.SNI
+	}
+	if c.Host != "" {
+		if host, _, err := net.SplitHostPort(c.Host); err == nil {
+			return host
+		}
+		return c.Host
+	}
+	return ""
+}
+
+// newTransport 创建连接到指定 IP 的 HTTP Transport，按配置指定 SNI 及是否跳过证书验证
+// 强制 HTTP/2 时通过 ALPN 优先协商 h2，服务器仍可能选择 HTTP/1.1，需再由 checkProtocol 检查响应
+// HTTP/3 时返回通过 UDP 连接到 IP 测速端口的 QUIC Transport，使用完毕后需通过 closeTransport 释放 UDP 连接
+func (c *Config) newTransport(ip *net.IPAddr) http.RoundTripper {
+	tlsConfig := &tls.Config{
+		ServerName:         c.serverName(),
+		InsecureSkipVerify: c.Insecure, // 跳过证书验证（用于自签名证书的测试源站）
+	}
+	if c.Protocol == ProtocolHTTP3 {
+		addr := net.JoinHostPort(ip.String(), strconv.Itoa(c.TCPPort))
+		return &http3.Transport{
+			TLSClientConfig: tlsConfig,
+			// 忽略测速地址的域名，直接连接到指定 IP
+			Dial: func(ctx context.Context, _ string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+				return quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+			},
+		}
+	}
+	transport := &http.Transport{
+		DialContext:     getDialContext(ip, c.TCPPort),
+		TLSClientConfig: tlsConfig,
+	}
+	if c.Protocol == ProtocolHTTP2 {
+		transport.ForceAttemptHTTP2 = true // 自定义了 DialContext 及 TLSClientConfig 时需显式启用
+		transport.TLSClientConfig.NextProtos = []string{"h2"}
+	}
+	return transport
+}
+
+// checkProtocol 检查响应使用的 HTTP 协议版本是否符合要求
+func (c *Config) checkProtocol(response *http.Response) bool {
+	switch c.Protocol {
+	case ProtocolHTTP2:
+		return response.ProtoMajor == 2
+	case ProtocolHTTP3:
+		return response.ProtoMajor == 3
+	}
+	return true
+}
+
+// closeTransport 关闭 Transport 持有的连接（HTTP/3 的 QUIC Transport 需关闭以释放 UDP 连接）
+func closeTransport(transport http.RoundTripper) {
+	if closer, ok := transport.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// transportBody 关闭响应 Body 时同时关闭 Transport，用于响应在创建 Transport 的函数返回后才读取完毕的情况
+type transportBody struct {
+	io.ReadCloser
+	transport http.RoundTripper
+}
+
+func (b *transportBody) Close() error {
+	err := b.ReadCloser.Close()
+	closeTransport(b.transport)
+	return err
+}
@@ -0,0 +1,179 @@
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// TestParseProtocol 测试解析 HTTP 协议版本
+func TestParseProtocol(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"", ProtocolHTTP1, false},
+		{"h1", ProtocolHTTP1, false},
+		{"HTTP/1.1", ProtocolHTTP1, false},
+		{"h2", ProtocolHTTP2, false},
+		{" http2 ", ProtocolHTTP2, false},
+		{"h3", ProtocolHTTP3, false},
+		{"quic", ProtocolHTTP3, false},
+		{"spdy", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseProtocol(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseProtocol(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseProtocol(%q) = %q, expected %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestDownloadHandler_Protocol 测试下载测速记录使用的 HTTP 协议版本，强制 HTTP/2 时拒绝 HTTP/1.1 响应
+func TestDownloadHandler_Protocol(t *testing.T) {
+	cfg, _ := downloadServer(t, 64*1024)
+	ip := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	result := NewScanner(cfg).downloadHandler(context.Background(), ip, nil)
+	if result.proto != "HTTP/1.1" {
+		t.Errorf("proto = %q, expected HTTP/1.1", result.proto)
+	}
+
+	cfg.Protocol = ProtocolHTTP2
+	result = NewScanner(cfg).downloadHandler(context.Background(), ip, nil)
+	if result.speed != 0 || result.proto != "" {
+		t.Errorf("expected HTTP/1.1 response to be rejected, got %+v", result)
+	}
+}
+
+// TestDownloadHandler_SNIHost 测试指定 SNI、Host 及跳过证书验证，并强制 HTTP/2
+func TestDownloadHandler_SNIHost(t *testing.T) {
+	var serverName, host string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName, host = r.TLS.ServerName, r.Host
+		w.Header().Set("Content-Length", "1024")
+		_, _ = w.Write(make([]byte, 1024))
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // 忽略证书验证失败时的握手错误日志
+	srv.StartTLS()
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.URL = "https://origin.example.com/test"
+	cfg.TCPPort = port
+	cfg.SNI = "speed.example.com"
+	cfg.Host = "zone.example.com"
+	cfg.Protocol = ProtocolHTTP2
+	ip := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	// 自签名证书，未跳过证书验证时测速失败
+	if result := NewScanner(cfg).downloadHandler(context.Background(), ip, nil); result.proto != "" {
+		t.Errorf("expected certificate error, got %+v", result)
+	}
+
+	cfg.Insecure = true
+	result := NewScanner(cfg).downloadHandler(context.Background(), ip, nil)
+	if result.proto != "HTTP/2.0" {
+		t.Errorf("proto = %q, expected HTTP/2.0", result.proto)
+	}
+	if serverName != cfg.SNI {
+		t.Errorf("SNI = %q, expected %q", serverName, cfg.SNI)
+	}
+	if host != cfg.Host {
+		t.Errorf("Host = %q, expected %q", host, cfg.Host)
+	}
+}
+
+// TestConfig_ServerName 测试未指定 SNI 时使用 Host 的域名
+func TestConfig_ServerName(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.serverName(); got != "" {
+		t.Errorf("serverName() = %q, expected empty", got)
+	}
+	cfg.Host = "zone.example.com:8443"
+	if got := cfg.serverName(); got != "zone.example.com" {
+		t.Errorf("serverName() = %q, expected zone.example.com", got)
+	}
+	cfg.SNI = "speed.example.com"
+	if got := cfg.serverName(); got != "speed.example.com" {
+		t.Errorf("serverName() = %q, expected speed.example.com", got)
+	}
+}
+
+// http3Server 启动本地 HTTP/3 测速服务器（自签名证书），返回对应的测速配置及记录请求 SNI 的变量
+func http3Server(t *testing.T, size int) (Config, *string) {
+	t.Helper()
+	cert := httptest.NewTLSServer(http.NotFoundHandler()) // 借用 httptest 的自签名证书
+	cert.Close()
+	var serverName string
+	srv := &http3.Server{
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: cert.TLS.Certificates}),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serverName = r.TLS.ServerName
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+			_, _ = w.Write(make([]byte, size))
+		}),
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(conn) }()
+	t.Cleanup(func() {
+		srv.Close()
+		conn.Close()
+	})
+
+	cfg := DefaultConfig()
+	cfg.URL = "https://speed.example.com/test"
+	cfg.TCPPort = conn.LocalAddr().(*net.UDPAddr).Port
+	cfg.Insecure = true
+	cfg.Protocol = ProtocolHTTP3
+	return cfg, &serverName
+}
+
+// TestDownloadHandler_HTTP3 测试通过 QUIC 连接到指定 IP 的 UDP 端口进行下载测速
+func TestDownloadHandler_HTTP3(t *testing.T) {
+	cfg, serverName := http3Server(t, 64*1024)
+	ip := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	result := NewScanner(cfg).downloadHandler(context.Background(), ip, nil)
+	if result.proto != "HTTP/3.0" || result.speed <= 0 {
+		t.Errorf("expected HTTP/3.0 download, got %+v", result)
+	}
+	if *serverName != "speed.example.com" {
+		t.Errorf("SNI = %q, expected speed.example.com", *serverName)
+	}
+}
+
+// TestHttping_HTTP3 测试 HTTPing 延迟测速使用 HTTP/3
+func TestHttping_HTTP3(t *testing.T) {
+	cfg, _ := http3Server(t, 16)
+	cfg.PingTimes = 2
+	p := &Ping{cfg: &cfg}
+
+	data := &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("127.0.0.1")}}
+	p.httping(context.Background(), data)
+	if len(data.Samples) != 2 || data.Proto != "HTTP/3.0" {
+		t.Errorf("samples = %d, proto = %q, expected 2 samples over HTTP/3.0", len(data.Samples), data.Proto)
+	}
+}
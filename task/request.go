@@ -0,0 +1,107 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+)
+
+const (
+	// 测速请求默认的 User-Agent
+	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36"
+
+	defaultHttpingMethod  = http.MethodHead
+	defaultDownloadMethod = http.MethodGet
+)
+
+// checkRequestDefault 检查并修正请求方法，需要校验响应体时 HTTPing 不使用 HEAD 请求
+func (c *Config) checkRequestDefault() {
+	c.HttpingMethod = strings.ToUpper(strings.TrimSpace(c.HttpingMethod))
+	if c.HttpingMethod == "" {
+		c.HttpingMethod = defaultHttpingMethod
+	}
+	if c.HttpingMethod == http.MethodHead && c.HttpingCheck.needBody() { // HEAD 请求没有响应体，无法校验
+		c.HttpingMethod = http.MethodGet
+	}
+	c.DownloadMethod = strings.ToUpper(strings.TrimSpace(c.DownloadMethod))
+	if c.DownloadMethod == "" {
+		c.DownloadMethod = defaultDownloadMethod
+	}
+}
+
+// newRequest 创建测速请求，依次设置默认 User-Agent、自定义请求头及 Host 头
+// 自定义请求头中的 Host 视为覆盖 Host 头，-host 参数优先
+func (c *Config) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for name, values := range c.Headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+	if c.Host != "" {
+		req.Host = c.Host
+	}
+	return req, nil
+}
+
+// requestBody 返回 HTTPing 及下载测速请求的请求体，未指定时为空
+func (c *Config) requestBody() io.Reader {
+	if c.Body == "" {
+		return nil
+	}
+	return strings.NewReader(c.Body)
+}
+
+// newCookieJar 创建 Cookie 容器，未启用时返回空
+func (c *Config) newCookieJar() http.CookieJar {
+	if !c.CookieJar {
+		return nil
+	}
+	jar, _ := cookiejar.New(nil) // 未指定公共后缀列表时不会返回错误
+	return jar
+}
+
+// ParseHeaders 解析 "名称: 值" 形式的请求头，并添加到 header 中
+// 配置文件中的列表以英文逗号连接，因此 value 中逗号后紧跟 "名称:" 时视为下一个请求头，否则视为值的一部分
+func ParseHeaders(header http.Header, value string) error {
+	var lines []string
+	for _, part := range strings.Split(value, ",") {
+		if len(lines) == 0 || isHeaderLine(part) {
+			lines = append(lines, part)
+		} else {
+			lines[len(lines)-1] += "," + part
+		}
+	}
+	for _, line := range lines {
+		i := strings.IndexByte(line, ':')
+		if i <= 0 || !isHeaderLine(line) {
+			return fmt.Errorf("请求头 %q 格式有误，应为 \"名称: 值\"", strings.TrimSpace(line))
+		}
+		header.Add(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+	}
+	return nil
+}
+
+// isHeaderLine 判断 s 是否以 "名称:" 开头（名称仅包含 HTTP 头部名称允许的字符）
+func isHeaderLine(s string) bool {
+	s = strings.TrimLeft(s, " ")
+	i := strings.IndexByte(s, ':')
+	if i <= 0 {
+		return false
+	}
+	for _, r := range s[:i] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
+		}
+	}
+	return true
+}
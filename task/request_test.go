@@ -0,0 +1,84 @@
+package task
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// TestParseHeaders 测试解析请求头，值中的逗号不视为分隔符
+func TestParseHeaders(t *testing.T) {
+	header := http.Header{}
+	if err := ParseHeaders(header, "Accept: text/html, application/json,X-Token: abc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("Accept"); got != "text/html, application/json" {
+		t.Errorf("Accept = %q, expected %q", got, "text/html, application/json")
+	}
+	if got := header.Get("X-Token"); got != "abc" {
+		t.Errorf("X-Token = %q, expected abc", got)
+	}
+
+	for _, value := range []string{"no-colon", ": empty", "Bad Name: x"} {
+		if err := ParseHeaders(http.Header{}, value); err == nil {
+			t.Errorf("ParseHeaders(%q) expected error", value)
+		}
+	}
+}
+
+// TestHttping_RequestOptions 测试 HTTPing 使用自定义的请求方法、请求头、请求体及 Cookie
+func TestHttping_RequestOptions(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests, bodies = append(requests, r), append(bodies, string(b))
+		mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.URL = srv.URL
+	cfg.TCPPort = port
+	cfg.PingTimes = 2
+	cfg.HttpingMethod = http.MethodPost
+	cfg.Body = "ping"
+	cfg.CookieJar = true
+	cfg.Headers = http.Header{}
+	_ = ParseHeaders(cfg.Headers, "User-Agent: cfst-test,Authorization: Bearer token")
+	p := &Ping{cfg: &cfg}
+
+	data := &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("127.0.0.1")}}
+	p.httping(context.Background(), data)
+	delays := data.Samples
+
+	if len(delays) != 2 || len(requests) != 3 {
+		t.Fatalf("expected 2 delays and 3 requests, got %d and %d", len(delays), len(requests))
+	}
+	for i, r := range requests {
+		if r.Method != http.MethodPost || bodies[i] != "ping" {
+			t.Errorf("request %d: method = %s, body = %q", i, r.Method, bodies[i])
+		}
+		if r.UserAgent() != "cfst-test" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("request %d: unexpected headers %v", i, r.Header)
+		}
+		if _, err := r.Cookie("session"); (err == nil) != (i > 0) { // 首次请求后携带服务器设置的 Cookie
+			t.Errorf("request %d: cookie err = %v", i, err)
+		}
+	}
+}
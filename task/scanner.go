@@ -0,0 +1,142 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// Scanner 测速器：持有独立配置，负责 延迟测速 → 过滤 → 下载测速 的完整流程
+type Scanner struct {
+	cfg      Config          // 测速配置
+	colomap  *sync.Map       // 地区码筛选映射表
+	sink     utils.Sink      // 实时输出延迟测速结果（可为空）
+	progress *utils.Progress // 测速进度（可为空）
+
+	statsMu sync.RWMutex
+	stats   ScanStats // 最近一次完整测速的统计
+}
+
+// ScanStats 单次测速的统计
+type ScanStats struct {
+	Tested           int           // 延迟测速的 IP 数量
+	Available        int           // 延迟测速可用的 IP 数量
+	Filtered         int           // 按延迟、丢包率过滤后的 IP 数量
+	Results          int           // 最终测速结果的 IP 数量
+	PingDuration     time.Duration // 延迟测速耗时
+	DownloadDuration time.Duration // 下载测速耗时
+	UploadDuration   time.Duration // 上传测速耗时
+	StartTime        time.Time     // 开始测速时间
+	EndTime          time.Time     // 结束测速时间
+}
+
+// Duration 返回测速总耗时
+func (st ScanStats) Duration() time.Duration {
+	return st.EndTime.Sub(st.StartTime)
+}
+
+// NewScanner 根据配置创建测速器，越界的参数会被修正为默认值
+func NewScanner(cfg Config) *Scanner {
+	cfg.checkPingDefault()
+	cfg.checkDownloadDefault()
+	cfg.checkUploadDefault()
+	cfg.checkProtocolDefault()
+	cfg.checkRequestDefault()
+	cfg.checkStatusDefault()
+	return &Scanner{
+		cfg:     cfg,
+		colomap: MapColoMap(cfg.HttpingCFColo),
+	}
+}
+
+// Config 返回修正后的测速配置
+func (s *Scanner) Config() Config {
+	return s.cfg
+}
+
+// SetSink 设置实时输出：每个 IP 延迟测速完成后立即发送给 sink
+func (s *Scanner) SetSink(sink utils.Sink) {
+	s.sink = sink
+}
+
+// SetProgress 设置测速进度：进度条的计数会同步到 progress
+func (s *Scanner) SetProgress(progress *utils.Progress) {
+	s.progress = progress
+}
+
+// NewPing 加载 IP 段数据并创建延迟测速实例，IP 段数据有误或无法读取时返回错误
+func (s *Scanner) NewPing() (*Ping, error) {
+	p, err := newPing(&s.cfg, s.colomap, s.sink)
+	if err != nil {
+		return nil, err
+	}
+	p.bar.Report(s.progress, utils.StagePing)
+	return p, nil
+}
+
+// RunPing 执行延迟测速，返回按丢包率、延迟排序的结果
+func (s *Scanner) RunPing(ctx context.Context) (utils.PingDelaySet, error) {
+	ping, err := s.NewPing()
+	if err != nil {
+		return nil, err
+	}
+	return ping.Run(ctx), nil
+}
+
+// Filter 按配置的延迟、丢包率、抖动条件过滤延迟测速结果
+// 指定了排序方式时，过滤后的结果按该方式重新排序（决定下载测速的顺序）
+func (s *Scanner) Filter(ipSet utils.PingDelaySet) utils.PingDelaySet {
+	ipSet = ipSet.FilterDelay(s.cfg.MinDelay, s.cfg.MaxDelay).FilterLossRate(s.cfg.MaxLossRate).FilterJitter(s.cfg.MaxJitter)
+	if order := s.cfg.order(); order != nil {
+		ipSet.SortBy(order)
+	}
+	return ipSet
+}
+
+// Run 执行完整测速流程：延迟测速 → 过滤延迟/丢包 → 下载测速 → 上传测速（指定了上传测速地址时）
+// ctx 取消后跳过剩余阶段，返回已获取的结果；IP 段数据有误或无法读取时返回错误
+func (s *Scanner) Run(ctx context.Context) (utils.DownloadSpeedSet, error) {
+	stats := ScanStats{StartTime: time.Now()}
+	ping, err := s.NewPing()
+	if err != nil {
+		return nil, err
+	}
+	stats.Tested = len(ping.ips)
+	pingData := ping.Run(ctx)
+	stats.Available = len(pingData)
+	pingData = s.Filter(pingData)
+	stats.Filtered = len(pingData)
+	stats.PingDuration = time.Since(stats.StartTime)
+
+	var speedData utils.DownloadSpeedSet
+	if ctx.Err() != nil {
+		speedData = utils.DownloadSpeedSet(pingData)
+	} else {
+		downloadStart := time.Now()
+		speedData = s.TestDownloadSpeed(ctx, pingData)
+		stats.DownloadDuration = time.Since(downloadStart)
+		if s.cfg.UploadURL != "" && ctx.Err() == nil {
+			uploadStart := time.Now()
+			s.TestUploadSpeed(ctx, speedData)
+			stats.UploadDuration = time.Since(uploadStart)
+		}
+		if order := s.cfg.order(); order != nil {
+			speedData.SortBy(order)
+		}
+	}
+	stats.Results = len(speedData)
+	stats.EndTime = time.Now()
+	s.statsMu.Lock()
+	s.stats = stats
+	s.statsMu.Unlock()
+	return speedData, nil
+}
+
+// Stats 返回最近一次完整测速的统计
+func (s *Scanner) Stats() ScanStats {
+	s.statsMu.RLock()
+	defer s.statsMu.RUnlock()
+	return s.stats
+}
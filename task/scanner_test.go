@@ -0,0 +1,155 @@
+package task
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// TestNewScanner_FixDefaults 测试创建测速器时修正越界参数
+func TestNewScanner_FixDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Routines = 0
+	cfg.TCPPort = 70000
+	cfg.URL = ""
+
+	got := NewScanner(cfg).Config()
+
+	if got.Routines != defaultRoutines {
+		t.Errorf("Routines = %d, expected %d", got.Routines, defaultRoutines)
+	}
+	if got.TCPPort != defaultPort {
+		t.Errorf("TCPPort = %d, expected %d", got.TCPPort, defaultPort)
+	}
+	if got.URL != defaultURL {
+		t.Errorf("URL = %s, expected %s", got.URL, defaultURL)
+	}
+	// 原配置不应被修改
+	if cfg.Routines != 0 {
+		t.Errorf("original Routines modified to %d", cfg.Routines)
+	}
+}
+
+// TestNewScanner_Independent 测试多个测速器的配置互不影响
+func TestNewScanner_Independent(t *testing.T) {
+	a := DefaultConfig()
+	a.TCPPort = 443
+	a.HttpingCFColo = "LAX"
+	b := DefaultConfig()
+	b.TCPPort = 8443
+
+	sa, sb := NewScanner(a), NewScanner(b)
+
+	if sa.Config().TCPPort != 443 || sb.Config().TCPPort != 8443 {
+		t.Errorf("TCPPort = %d/%d, expected 443/8443", sa.Config().TCPPort, sb.Config().TCPPort)
+	}
+	if sa.colomap == nil {
+		t.Error("colomap of scanner a is nil")
+	}
+	if sb.colomap != nil {
+		t.Error("colomap of scanner b should be nil")
+	}
+}
+
+// TestScanner_Filter 测试按配置过滤延迟和丢包率
+func TestScanner_Filter(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxDelay = 200 * time.Millisecond
+	cfg.MaxLossRate = 0.25
+
+	data := utils.PingDelaySet{
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4, Delay: 100 * time.Millisecond}},
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("2.2.2.2")}, Sended: 4, Received: 2, Delay: 150 * time.Millisecond}},
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("3.3.3.3")}, Sended: 4, Received: 4, Delay: 300 * time.Millisecond}},
+	}
+
+	filtered := NewScanner(cfg).Filter(data)
+
+	if len(filtered) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(filtered))
+	}
+	if filtered[0].IP.String() != "1.1.1.1" {
+		t.Errorf("expected 1.1.1.1, got %s", filtered[0].IP.String())
+	}
+}
+
+// TestScanner_Filter_Sort 测试指定排序方式时过滤后的结果按该方式重新排序
+func TestScanner_Filter_Sort(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Sort = utils.SortOrder{{Name: "colo"}, {Name: "delay", Desc: true}}
+
+	data := utils.PingDelaySet{
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4, Delay: 100 * time.Millisecond, Colo: "SJC"}},
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("2.2.2.2")}, Sended: 4, Received: 4, Delay: 150 * time.Millisecond, Colo: "HKG"}},
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("3.3.3.3")}, Sended: 4, Received: 4, Delay: 200 * time.Millisecond, Colo: "SJC"}},
+	}
+
+	filtered := NewScanner(cfg).Filter(data)
+
+	want := []string{"2.2.2.2", "3.3.3.3", "1.1.1.1"}
+	for i, ip := range want {
+		if filtered[i].IP.String() != ip {
+			t.Errorf("filtered[%d] = %s, expected %s", i, filtered[i].IP, ip)
+		}
+	}
+}
+
+// TestScanner_TestDownloadSpeed_Disabled 测试禁用下载测速时直接返回延迟测速结果
+func TestScanner_TestDownloadSpeed_Disabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DisableDownload = true
+
+	data := utils.PingDelaySet{
+		{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4}},
+	}
+
+	speedSet := NewScanner(cfg).TestDownloadSpeed(context.Background(), data)
+
+	if len(speedSet) != 1 {
+		t.Errorf("expected 1 result, got %d", len(speedSet))
+	}
+}
+
+// TestScanner_Run_Stats 测试完整测速流程的统计
+func TestScanner_Run_Stats(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	cfg := DefaultConfig()
+	cfg.IPText = "127.0.0.1"
+	cfg.TCPPort = ln.Addr().(*net.TCPAddr).Port
+	cfg.PingTimes = 1
+	cfg.DisableDownload = true
+	s := NewScanner(cfg)
+
+	data, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := s.Stats()
+
+	if len(data) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(data))
+	}
+	if stats.Tested != 1 || stats.Available != 1 || stats.Filtered != 1 || stats.Results != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.EndTime.Before(stats.StartTime) || stats.Duration() < stats.PingDuration {
+		t.Errorf("unexpected durations: %+v", stats)
+	}
+}
@@ -0,0 +1,89 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 未指定有效状态码时的默认值
+var (
+	defaultHttpingStatusCodes  = StatusCodes{{200, 200}, {301, 302}}
+	defaultDownloadStatusCodes = StatusCodes{{200, 200}}
+)
+
+// statusRange 闭区间的 HTTP 状态码范围
+type statusRange struct {
+	min, max int
+}
+
+// StatusCodes 有效的 HTTP 状态码列表，如 200,204,3xx,400-404
+type StatusCodes []statusRange
+
+// ParseStatusCodes 解析英文逗号分隔的 HTTP 状态码列表，支持单个状态码、2xx 形式及 200-299 形式的范围
+func ParseStatusCodes(s string) (StatusCodes, error) {
+	var codes StatusCodes
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		var r statusRange
+		var err error
+		switch {
+		case len(item) == 3 && strings.HasSuffix(item, "xx"):
+			r.min, err = strconv.Atoi(item[:1])
+			r.min *= 100
+			r.max = r.min + 99
+		case strings.Contains(item, "-"):
+			min, max, _ := strings.Cut(item, "-")
+			if r.min, err = strconv.Atoi(strings.TrimSpace(min)); err == nil {
+				r.max, err = strconv.Atoi(strings.TrimSpace(max))
+			}
+		default:
+			r.min, err = strconv.Atoi(item)
+			r.max = r.min
+		}
+		if err != nil || r.min < 100 || r.max > 599 || r.min > r.max {
+			return nil, fmt.Errorf("无效的 HTTP 状态码 %q，范围为 100~599，支持 200、2xx、200-299 形式", item)
+		}
+		codes = append(codes, r)
+	}
+	return codes, nil
+}
+
+// Match 返回状态码是否有效
+func (c StatusCodes) Match(code int) bool {
+	for _, r := range c {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// String 返回状态码列表的文本形式
+func (c StatusCodes) String() string {
+	items := make([]string, 0, len(c))
+	for _, r := range c {
+		switch {
+		case r.min == r.max:
+			items = append(items, strconv.Itoa(r.min))
+		case r.min%100 == 0 && r.max == r.min+99:
+			items = append(items, strconv.Itoa(r.min/100)+"xx")
+		default:
+			items = append(items, strconv.Itoa(r.min)+"-"+strconv.Itoa(r.max))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// checkStatusDefault 未指定有效状态码时使用默认值
+func (c *Config) checkStatusDefault() {
+	if len(c.HttpingStatusCodes) == 0 {
+		c.HttpingStatusCodes = defaultHttpingStatusCodes
+	}
+	if len(c.DownloadStatusCodes) == 0 {
+		c.DownloadStatusCodes = defaultDownloadStatusCodes
+	}
+}
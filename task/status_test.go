@@ -0,0 +1,99 @@
+package task
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// TestParseStatusCodes 测试解析有效状态码列表及范围
+func TestParseStatusCodes(t *testing.T) {
+	codes, err := ParseStatusCodes("200, 204,3XX,400-403")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for code, want := range map[int]bool{200: true, 201: false, 204: true, 300: true, 399: true, 402: true, 404: false, 500: false} {
+		if got := codes.Match(code); got != want {
+			t.Errorf("Match(%d) = %v, expected %v", code, got, want)
+		}
+	}
+	if got := codes.String(); got != "200,204,3xx,400-403" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, s := range []string{"99", "600", "6xx", "abc", "300-200", "2x"} {
+		if _, err := ParseStatusCodes(s); err == nil {
+			t.Errorf("ParseStatusCodes(%q) expected error", s)
+		}
+	}
+	if codes, err := ParseStatusCodes(""); err != nil || codes != nil {
+		t.Errorf("ParseStatusCodes(\"\") = %v, %v", codes, err)
+	}
+}
+
+// TestHttping_RejectedCodes 测试 HTTPing 按有效状态码过滤并统计被拒绝的状态码
+func TestHttping_RejectedCodes(t *testing.T) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 3 { // 第 2 次延迟测速返回 503
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.URL = srv.URL
+	cfg.TCPPort = port
+	cfg.PingTimes = 3
+	p := &Ping{cfg: &cfg}
+	ip := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	// 默认只接受 200、301、302
+	data := &utils.PingData{IP: ip}
+	p.httping(context.Background(), data)
+	if len(data.Samples) != 0 || data.Rejected.String() != "204x1" {
+		t.Errorf("default codes: samples = %d, rejected = %q", len(data.Samples), data.Rejected)
+	}
+
+	atomic.StoreInt32(&n, 0)
+	cfg.HttpingStatusCodes, _ = ParseStatusCodes("2xx")
+	data = &utils.PingData{IP: ip}
+	p.httping(context.Background(), data)
+	if len(data.Samples) != 2 || data.Rejected.String() != "503x1" {
+		t.Errorf("2xx: samples = %d, rejected = %q", len(data.Samples), data.Rejected)
+	}
+}
+
+// TestDownloadHandler_RejectedCodes 测试下载测速统计被拒绝的状态码
+func TestDownloadHandler_RejectedCodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.URL = srv.URL
+	cfg.TCPPort = port
+	cfg.DownloadConns = 2
+	s := NewScanner(cfg)
+	data := utils.CloudflareIPData{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("127.0.0.1")}}}
+
+	s.recordSpeed(&data, s.downloadHandler(context.Background(), data.IP, nil))
+
+	if got := data.Rejected.String(); got != "403x2" {
+		t.Errorf("rejected = %q, expected 403x2", got)
+	}
+}
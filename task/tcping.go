@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sort"
@@ -19,89 +20,102 @@ const (
 	defaultPingTimes  = 4
 )
 
-var (
-	Routines      = defaultRoutines
-	TCPPort   int = defaultPort
-	PingTimes int = defaultPingTimes
-)
-
 // Ping 结构体：TCP/HTTP ping 测试
 type Ping struct {
-	wg      *sync.WaitGroup       // 用于等待所有 goroutine 完成
-	m       *sync.Mutex           // 互斥锁，保护并发写入
-	ips     []*net.IPAddr         // 待测试的 IP 列表
-	csv     utils.PingDelaySet    // 测试结果集
-	control chan bool             // 控制并发数量的通道
-	bar     *utils.Bar            // 进度条
+	wg      *sync.WaitGroup    // 用于等待所有 goroutine 完成
+	m       *sync.Mutex        // 互斥锁，保护并发写入
+	ips     []*net.IPAddr      // 待测试的 IP 列表
+	csv     utils.PingDelaySet // 测试结果集
+	control chan bool          // 控制并发数量的通道
+	bar     *utils.Bar         // 进度条
+	cfg     *Config            // 测速配置
+	colomap *sync.Map          // 地区码筛选映射表
+	sink    utils.Sink         // 实时输出测速结果（可为空）
 }
 
 // 检查并修正默认参数
-func checkPingDefault() {
-	if Routines <= 0 {
-		Routines = defaultRoutines
+func (c *Config) checkPingDefault() {
+	if c.Routines <= 0 {
+		c.Routines = defaultRoutines
 	}
-	if TCPPort <= 0 || TCPPort >= 65535 {
-		TCPPort = defaultPort
+	if c.TCPPort <= 0 || c.TCPPort >= 65535 {
+		c.TCPPort = defaultPort
 	}
-	if PingTimes <= 0 {
-		PingTimes = defaultPingTimes
+	if c.PingTimes <= 0 {
+		c.PingTimes = defaultPingTimes
 	}
 }
 
-// 创建新的 Ping 实例
-func NewPing() *Ping {
-	checkPingDefault()
-	ips := loadIPRanges()
+// 创建新的 Ping 实例，IP 段数据有误或无法读取时返回错误
+func newPing(cfg *Config, colomap *sync.Map, sink utils.Sink) (*Ping, error) {
+	ips, err := loadIPRanges(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return &Ping{
 		wg:      &sync.WaitGroup{},
 		m:       &sync.Mutex{},
 		ips:     ips,
 		csv:     make(utils.PingDelaySet, 0),
-		control: make(chan bool, Routines), // 缓冲通道，控制并发数
+		control: make(chan bool, cfg.Routines), // 缓冲通道，控制并发数
 		bar:     utils.NewBar(len(ips), "可用:", ""),
-	}
+		cfg:     cfg,
+		colomap: colomap,
+		sink:    sink,
+	}, nil
 }
 
 // Run 执行延迟测速
-func (p *Ping) Run() utils.PingDelaySet {
+// ctx 取消后不再启动新的测试，等待进行中的测试结束后返回已获取的结果
+func (p *Ping) Run(ctx context.Context) utils.PingDelaySet {
 	if len(p.ips) == 0 {
 		return p.csv
 	}
-	if Httping {
-		utils.Cyan.Printf("开始延迟测速（模式：HTTP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n", TCPPort, utils.InputMinDelay.Milliseconds(), utils.InputMaxDelay.Milliseconds(), utils.InputMaxLossRate)
+	if p.cfg.Httping {
+		utils.Cyan.Printf("开始延迟测速（模式：HTTP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n", p.cfg.TCPPort, p.cfg.MinDelay.Milliseconds(), p.cfg.MaxDelay.Milliseconds(), p.cfg.MaxLossRate)
+	} else if p.cfg.TLSPing {
+		utils.Cyan.Printf("开始延迟测速（模式：TLS, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n", p.cfg.TCPPort, p.cfg.MinDelay.Milliseconds(), p.cfg.MaxDelay.Milliseconds(), p.cfg.MaxLossRate)
 	} else {
-		utils.Cyan.Printf("开始延迟测速（模式：TCP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n", TCPPort, utils.InputMinDelay.Milliseconds(), utils.InputMaxDelay.Milliseconds(), utils.InputMaxLossRate)
+		utils.Cyan.Printf("开始延迟测速（模式：TCP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n", p.cfg.TCPPort, p.cfg.MinDelay.Milliseconds(), p.cfg.MaxDelay.Milliseconds(), p.cfg.MaxLossRate)
 	}
 	// 启动多个 goroutine 进行并发测试
+loop:
 	for _, ip := range p.ips {
+		if ctx.Err() != nil { // 已取消，不再启动新的测试
+			break
+		}
+		select {
+		case p.control <- false: // 占用一个并发名额
+		case <-ctx.Done():
+			break loop
+		}
 		p.wg.Add(1)
-		p.control <- false // 占用一个并发名额
-		go p.start(ip)
+		go p.start(ctx, ip)
 	}
-	p.wg.Wait()       // 等待所有测试完成
-	p.bar.Done()       // 完成进度条
-	sort.Sort(p.csv)  // 按丢包率、延迟排序
+	p.wg.Wait()      // 等待所有测试完成
+	p.bar.Done()     // 完成进度条
+	sort.Sort(p.csv) // 按丢包率、延迟排序
 	return p.csv
 }
 
 // start 启动单个 IP 的测试 goroutine
-func (p *Ping) start(ip *net.IPAddr) {
-	defer p.wg.Done()      // 标记完成
-	p.tcpingHandler(ip)    // 执行测试
-	<-p.control            // 释放一个并发名额
+func (p *Ping) start(ctx context.Context, ip *net.IPAddr) {
+	defer p.wg.Done()        // 标记完成
+	p.tcpingHandler(ctx, ip) // 执行测试
+	<-p.control              // 释放一个并发名额
 }
 
 // tcping 执行 TCP 连接测试
 // 返回值：连接是否成功、连接耗时
-func (p *Ping) tcping(ip *net.IPAddr) (bool, time.Duration) {
+func (p *Ping) tcping(ctx context.Context, ip *net.IPAddr) (bool, time.Duration) {
 	startTime := time.Now()
 	var fullAddress string
 	if isIPv4(ip.String()) {
-		fullAddress = fmt.Sprintf("%s:%d", ip.String(), TCPPort)
+		fullAddress = fmt.Sprintf("%s:%d", ip.String(), p.cfg.TCPPort)
 	} else {
-		fullAddress = fmt.Sprintf("[%s]:%d", ip.String(), TCPPort)
+		fullAddress = fmt.Sprintf("[%s]:%d", ip.String(), p.cfg.TCPPort)
 	}
-	conn, err := net.DialTimeout("tcp", fullAddress, tcpConnectTimeout)
+	conn, err := (&net.Dialer{Timeout: tcpConnectTimeout}).DialContext(ctx, "tcp", fullAddress)
 	if err != nil {
 		return false, 0
 	}
@@ -111,35 +125,49 @@ func (p *Ping) tcping(ip *net.IPAddr) (bool, time.Duration) {
 }
 
 // checkConnection 检查 IP 连接情况
-// 返回值：成功次数、总延迟、地区码
-func (p *Ping) checkConnection(ip *net.IPAddr) (recv int, totalDelay time.Duration, colo string) {
-	if Httping {
-		recv, totalDelay, colo = p.httping(ip)
-		return
+// 返回值：每次成功测速的延迟（Samples）、地区码及 HTTP 协议版本（仅 HTTPing 模式）、TLS 握手信息（仅 TLSPing 模式）
+func (p *Ping) checkConnection(ctx context.Context, ip *net.IPAddr) *utils.PingData {
+	data := &utils.PingData{IP: ip, Sended: p.cfg.PingTimes}
+	if p.cfg.Httping {
+		p.httping(ctx, data)
+		return data
+	}
+	if p.cfg.TLSPing {
+		data.Samples, data.TLS = p.tlsping(ctx, ip)
+		return data
 	}
-	colo = "" // TCPing 模式不获取 colo
-	// 执行多次 TCP 连接测试
-	for i := 0; i < PingTimes; i++ {
-		if ok, delay := p.tcping(ip); ok {
-			recv++
-			totalDelay += delay
+	// 执行多次 TCP 连接测试（TCPing 模式不获取 colo）
+	for i := 0; i < p.cfg.PingTimes && ctx.Err() == nil; i++ {
+		if ok, delay := p.tcping(ctx, ip); ok {
+			data.Samples = append(data.Samples, delay)
 		}
 	}
-	return
+	return data
 }
 
-// appendIPData 线程安全地添加 IP 测试数据
+// appendIPData 线程安全地添加 IP 测试数据，并实时输出
 func (p *Ping) appendIPData(data *utils.PingData) {
-	p.m.Lock()
-	defer p.m.Unlock()
-	p.csv = append(p.csv, utils.CloudflareIPData{
+	ipData := utils.CloudflareIPData{
 		PingData: data,
-	})
+	}
+	p.m.Lock()
+	p.csv = append(p.csv, ipData)
+	p.m.Unlock()
+	if p.sink == nil {
+		return
+	}
+	if err := p.sink.Send(ipData); err != nil && p.cfg.Debug {
+		utils.Red.Printf("[调试] IP: %s, 实时输出测速结果失败，错误信息: %v\n", data.IP.String(), err)
+	}
 }
 
 // tcpingHandler 处理单个 IP 的 ping 测试
-func (p *Ping) tcpingHandler(ip *net.IPAddr) {
-	recv, totalDlay, colo := p.checkConnection(ip)
+func (p *Ping) tcpingHandler(ctx context.Context, ip *net.IPAddr) {
+	data := p.checkConnection(ctx, ip)
+	if ctx.Err() != nil { // 测试被中断，结果不完整，直接丢弃
+		return
+	}
+	recv := len(data.Samples)
 	nowAble := len(p.csv)
 	if recv != 0 {
 		nowAble++
@@ -149,12 +177,12 @@ func (p *Ping) tcpingHandler(ip *net.IPAddr) {
 		return
 	}
 	// 计算平均延迟
-	data := &utils.PingData{
-		IP:       ip,
-		Sended:   PingTimes,
-		Received: recv,
-		Delay:    totalDlay / time.Duration(recv),
-		Colo:     colo,
+	var totalDelay time.Duration
+	for _, d := range data.Samples {
+		totalDelay += d
 	}
+	data.Received = recv
+	data.Delay = totalDelay / time.Duration(recv)
+	data.UpdateStats()
 	p.appendIPData(data)
 }
@@ -1,37 +1,31 @@
 package task
 
 import (
+	"context"
+	"net"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
 )
 
 // TestCheckPingDefault 测试 TCPing 默认参数校验功能
 // 验证参数越界时能正确重置为默认值
 func TestCheckPingDefault(t *testing.T) {
-	// 保存原始值
-	originalRoutines := Routines
-	originalTCPPort := TCPPort
-	originalPingTimes := PingTimes
-
-	defer func() {
-		Routines = originalRoutines
-		TCPPort = originalTCPPort
-		PingTimes = originalPingTimes
-	}()
-
 	tests := []struct {
 		name          string
-		setup         func()
+		setup         func(c *Config)
 		wantRoutines  int
 		wantTCPPort   int
 		wantPingTimes int
 	}{
 		{
 			name: "有效值",
-			setup: func() {
-				Routines = 500
-				TCPPort = 8443
-				PingTimes = 6
+			setup: func(c *Config) {
+				c.Routines = 500
+				c.TCPPort = 8443
+				c.PingTimes = 6
 			},
 			wantRoutines:  500,
 			wantTCPPort:   8443,
@@ -39,10 +33,10 @@ func TestCheckPingDefault(t *testing.T) {
 		},
 		{
 			name: "零值重置为默认",
-			setup: func() {
-				Routines = 0
-				TCPPort = 443
-				PingTimes = 4
+			setup: func(c *Config) {
+				c.Routines = 0
+				c.TCPPort = 443
+				c.PingTimes = 4
 			},
 			wantRoutines:  defaultRoutines,
 			wantTCPPort:   443,
@@ -50,10 +44,10 @@ func TestCheckPingDefault(t *testing.T) {
 		},
 		{
 			name: "负值重置为默认",
-			setup: func() {
-				Routines = -100
-				TCPPort = 443
-				PingTimes = 4
+			setup: func(c *Config) {
+				c.Routines = -100
+				c.TCPPort = 443
+				c.PingTimes = 4
 			},
 			wantRoutines:  defaultRoutines,
 			wantTCPPort:   443,
@@ -61,10 +55,10 @@ func TestCheckPingDefault(t *testing.T) {
 		},
 		{
 			name: "无效端口零值重置为默认",
-			setup: func() {
-				Routines = 200
-				TCPPort = 0
-				PingTimes = 4
+			setup: func(c *Config) {
+				c.Routines = 200
+				c.TCPPort = 0
+				c.PingTimes = 4
 			},
 			wantRoutines:  200,
 			wantTCPPort:   defaultPort,
@@ -72,10 +66,10 @@ func TestCheckPingDefault(t *testing.T) {
 		},
 		{
 			name: "无效端口最大值重置为默认",
-			setup: func() {
-				Routines = 200
-				TCPPort = 65535
-				PingTimes = 4
+			setup: func(c *Config) {
+				c.Routines = 200
+				c.TCPPort = 65535
+				c.PingTimes = 4
 			},
 			wantRoutines:  200,
 			wantTCPPort:   defaultPort,
@@ -83,10 +77,10 @@ func TestCheckPingDefault(t *testing.T) {
 		},
 		{
 			name: "零次 ping 重置为默认",
-			setup: func() {
-				Routines = 200
-				TCPPort = 443
-				PingTimes = 0
+			setup: func(c *Config) {
+				c.Routines = 200
+				c.TCPPort = 443
+				c.PingTimes = 0
 			},
 			wantRoutines:  200,
 			wantTCPPort:   443,
@@ -96,17 +90,18 @@ func TestCheckPingDefault(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.setup()
-			checkPingDefault()
+			var c Config
+			tt.setup(&c)
+			c.checkPingDefault()
 
-			if Routines != tt.wantRoutines {
-				t.Errorf("Routines = %d, expected %d", Routines, tt.wantRoutines)
+			if c.Routines != tt.wantRoutines {
+				t.Errorf("Routines = %d, expected %d", c.Routines, tt.wantRoutines)
 			}
-			if TCPPort != tt.wantTCPPort {
-				t.Errorf("TCPPort = %d, expected %d", TCPPort, tt.wantTCPPort)
+			if c.TCPPort != tt.wantTCPPort {
+				t.Errorf("TCPPort = %d, expected %d", c.TCPPort, tt.wantTCPPort)
 			}
-			if PingTimes != tt.wantPingTimes {
-				t.Errorf("PingTimes = %d, expected %d", PingTimes, tt.wantPingTimes)
+			if c.PingTimes != tt.wantPingTimes {
+				t.Errorf("PingTimes = %d, expected %d", c.PingTimes, tt.wantPingTimes)
 			}
 		})
 	}
@@ -114,25 +109,13 @@ func TestCheckPingDefault(t *testing.T) {
 
 // TestNewPing_Defaults 测试新建 Ping 对象功能
 func TestNewPing_Defaults(t *testing.T) {
-	// 重置为默认值
-	Routines = defaultRoutines
-	TCPPort = defaultPort
-	PingTimes = defaultPingTimes
-	IPFile = defaultInputFile
-	IPText = ""
-
-	// 临时使用 IPText 避免文件问题
-	originalIPFile := IPFile
-	originalIPText := IPText
-	IPText = "1.1.1.1"
-	defer func() {
-		IPFile = originalIPFile
-		IPText = originalIPText
-	}()
-
-	ping := NewPing()
-	if ping == nil {
-		t.Error("NewPing returned nil")
+	cfg := DefaultConfig()
+	// 使用 IPText 避免文件问题
+	cfg.IPText = "1.1.1.1"
+
+	ping, err := NewScanner(cfg).NewPing()
+	if err != nil || ping == nil {
+		t.Fatalf("NewPing returned %v, %v", ping, err)
 	}
 	if ping.wg == nil {
 		t.Error("WaitGroup is nil")
@@ -163,3 +146,51 @@ func TestConstants(t *testing.T) {
 		t.Errorf("defaultPingTimes = %d, want 4", defaultPingTimes)
 	}
 }
+
+// TestPing_Run_Canceled 测试上下文取消后不再启动新的测试
+func TestPing_Run_Canceled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.IPText = "1.1.1.1,2.2.2.2,3.3.3.3"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := NewScanner(cfg).RunPing(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 0 {
+		t.Errorf("expected 0 results after cancel, got %d", len(result))
+	}
+}
+
+type recordSink struct {
+	m    sync.Mutex
+	data []utils.CloudflareIPData
+}
+
+func (s *recordSink) Send(data utils.CloudflareIPData) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.data = append(s.data, data)
+	return nil
+}
+
+// TestPing_AppendIPData_Sink 测试测速结果会实时发送给 sink
+func TestPing_AppendIPData_Sink(t *testing.T) {
+	sink := &recordSink{}
+	p := &Ping{m: &sync.Mutex{}, cfg: &Config{}, sink: sink}
+
+	p.appendIPData(&utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4})
+
+	if len(p.csv) != 1 {
+		t.Errorf("expected 1 result collected, got %d", len(p.csv))
+	}
+	if len(sink.data) != 1 {
+		t.Fatalf("expected 1 result streamed, got %d", len(sink.data))
+	}
+	if sink.data[0].IP.String() != "1.1.1.1" {
+		t.Errorf("expected 1.1.1.1, got %s", sink.data[0].IP.String())
+	}
+}
@@ -0,0 +1,112 @@
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+const tlsHandshakeTimeout = time.Second * 2
+
+// TLS 版本名称
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// tlsping 执行 TLS 握手延迟测试：分别记录 TCP 连接耗时与 TLS 握手耗时，SNI 为指定的 SNI 或测速地址的域名
+// 首次握手成功时记录协商的 TLS 版本、ALPN，并检查证书对测速地址的域名是否有效（证书无效不影响测速）
+// 返回值：每次成功测速的总延迟（TCP 连接 + TLS 握手）、TLS 握手信息
+func (p *Ping) tlsping(ctx context.Context, ip *net.IPAddr) ([]time.Duration, *utils.TLSInfo) {
+	host := p.cfg.serverName()
+	if host == "" {
+		host = p.cfg.URL
+		if u, err := url.Parse(p.cfg.URL); err == nil && u.Hostname() != "" {
+			host = u.Hostname()
+		}
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true, // 握手后单独检查证书，以便记录证书是否有效
+	}
+
+	var (
+		delays                   []time.Duration
+		totalConnect, totalShake time.Duration
+		info                     *utils.TLSInfo
+	)
+	for i := 0; i < p.cfg.PingTimes && ctx.Err() == nil; i++ {
+		startTime := time.Now()
+		conn, err := (&net.Dialer{Timeout: tcpConnectTimeout}).DialContext(ctx, "tcp", joinHostPort(ip, p.cfg.TCPPort))
+		if err != nil {
+			continue
+		}
+		connectTime := time.Since(startTime)
+
+		shakeStart := time.Now()
+		hctx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(hctx)
+		cancel()
+		shakeTime := time.Since(shakeStart)
+		if err != nil {
+			conn.Close()
+			if p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, TLS 握手失败，错误信息: %v, SNI: %s\n", ip.String(), err, host)
+			}
+			continue
+		}
+		if info == nil {
+			info = newTLSInfo(tlsConn.ConnectionState(), host)
+			if !info.CertValid && p.cfg.Debug {
+				utils.Red.Printf("[调试] IP: %s, 证书无效，错误信息: %s, SNI: %s\n", ip.String(), info.CertError, host)
+			}
+		}
+		tlsConn.Close()
+		delays = append(delays, connectTime+shakeTime)
+		totalConnect += connectTime
+		totalShake += shakeTime
+	}
+	if info != nil {
+		info.Connect = totalConnect / time.Duration(len(delays))
+		info.Handshake = totalShake / time.Duration(len(delays))
+	}
+	return delays, info
+}
+
+// newTLSInfo 根据握手结果生成 TLS 握手信息，并检查证书对 host 是否有效
+func newTLSInfo(state tls.ConnectionState, host string) *utils.TLSInfo {
+	info := &utils.TLSInfo{
+		Version: tlsVersionNames[state.Version],
+		ALPN:    state.NegotiatedProtocol,
+	}
+	if len(state.PeerCertificates) == 0 {
+		info.CertError = "服务器未提供证书"
+		return info
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates})
+	if err != nil {
+		info.CertError = err.Error()
+	} else {
+		info.CertValid = true
+	}
+	return info
+}
+
+// joinHostPort 拼接 IP 与端口，IPv6 地址加上方括号
+func joinHostPort(ip *net.IPAddr, port int) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
@@ -0,0 +1,68 @@
+package task
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// TestTLSPing 测试 TLS 握手延迟测速记录的握手信息
+// httptest 的证书不受系统信任，证书应判定为无效但不影响测速
+func TestTLSPing(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.TLSPing = true
+	cfg.TCPPort = port
+	cfg.PingTimes = 3
+	cfg.URL = "https://example.com/"
+	p := &Ping{cfg: &cfg}
+
+	delays, info := p.tlsping(context.Background(), &net.IPAddr{IP: net.ParseIP("127.0.0.1")})
+
+	if len(delays) != 3 {
+		t.Fatalf("expected 3 successful handshakes, got %d", len(delays))
+	}
+	if info == nil {
+		t.Fatal("expected TLS info")
+	}
+	if info.Connect <= 0 || info.Handshake <= 0 || delays[0] < info.Connect {
+		t.Errorf("unexpected timings: connect=%v handshake=%v delays=%v", info.Connect, info.Handshake, delays)
+	}
+	if info.Version != "TLS 1.3" || info.ALPN != "h2" {
+		t.Errorf("Version = %q, ALPN = %q, expected TLS 1.3, h2", info.Version, info.ALPN)
+	}
+	if info.CertValid || info.CertError == "" {
+		t.Errorf("CertValid = %v, CertError = %q, expected invalid certificate", info.CertValid, info.CertError)
+	}
+	if cfg.Mode() != "tlsping" {
+		t.Errorf("Mode() = %q, expected tlsping", cfg.Mode())
+	}
+}
+
+// TestTLSPing_Refused 测试端口不可用时没有成功的握手
+func TestTLSPing_Refused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	cfg := DefaultConfig()
+	cfg.TCPPort = port
+	p := &Ping{cfg: &cfg}
+	delays, info := p.tlsping(context.Background(), &net.IPAddr{IP: net.ParseIP("127.0.0.1")})
+	if len(delays) != 0 || info != nil {
+		t.Errorf("expected no result, got %v, %+v", delays, info)
+	}
+}
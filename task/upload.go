@@ -0,0 +1,203 @@
+package task
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+
+	"github.com/VividCortex/ewma"
+)
+
+const (
+	defaultUploadSize int64 = 100 * 1024 * 1024
+	maxUploadSize     int64 = 10240 * 1024 * 1024
+	uploadBufferSize        = 32 * 1024
+)
+
+// checkUploadDefault 检查并修正上传测速默认参数
+func (c *Config) checkUploadDefault() {
+	if c.UploadSize <= 0 {
+		c.UploadSize = defaultUploadSize
+	} else if c.UploadSize > maxUploadSize {
+		c.UploadSize = maxUploadSize
+	}
+}
+
+// TestUploadSpeed 测试上传速度：对 ipSet 中的前 TestCount 个 IP 逐个测速，结果写入 UploadSpeed（原地）
+// 未指定上传测速地址时跳过；ctx 取消后中止当前测速并不再测试后续 IP
+func (s *Scanner) TestUploadSpeed(ctx context.Context, ipSet utils.DownloadSpeedSet) {
+	if s.cfg.UploadURL == "" || len(ipSet) == 0 {
+		return
+	}
+	testNum := s.cfg.TestCount
+	if len(ipSet) < testNum {
+		testNum = len(ipSet)
+	}
+
+	utils.Cyan.Printf("开始上传测速（数量：%d, 数据量：%.2f MB）\n", testNum, float64(s.cfg.UploadSize)/1024/1024)
+	// 调整进度条宽度以对齐
+	bar_a := len(strconv.Itoa(len(ipSet)))
+	bar_b := "     "
+	for i := 0; i < bar_a; i++ {
+		bar_b += " "
+	}
+	bar := utils.NewBar(testNum, bar_b, "").Report(s.progress, utils.StageUpload)
+	for i := 0; i < testNum && ctx.Err() == nil; i++ {
+		speed := s.uploadHandler(ctx, ipSet[i].IP)
+		if ctx.Err() != nil { // 测速被中断，结果不完整，直接丢弃
+			break
+		}
+		ipSet[i].UploadSpeed = speed
+		bar.Grow(1, "")
+	}
+	bar.Done()
+}
+
+// uploadPayload 上传测速的数据，统计已被读取（发送）的字节数
+type uploadPayload struct {
+	remaining int64          // 剩余字节数
+	sent      bandwidthMeter // 已发送的字节数
+	started   chan struct{}  // 开始发送时关闭
+	block     [64]byte       // 重复发送的数据块
+}
+
+func newUploadPayload(size int64) *uploadPayload {
+	p := &uploadPayload{remaining: size, started: make(chan struct{})}
+	for i := range p.block {
+		p.block[i] = byte(i*131 + 7) // 避免全零数据被中间设备压缩
+	}
+	return p
+}
+
+func (p *uploadPayload) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if p.sent.total() == 0 {
+		select {
+		case <-p.started:
+		default:
+			close(p.started)
+		}
+	}
+	if int64(len(b)) > p.remaining {
+		b = b[:p.remaining]
+	}
+	n := 0
+	for n < len(b) {
+		n += copy(b[n:], p.block[:])
+	}
+	p.remaining -= int64(n)
+	p.sent.add(n)
+	return n, nil
+}
+
+// uploadHandler 执行单个 IP 的上传测速：通过 POST 请求发送生成的数据，按 EWMA 算法计算平均速度
+// 建立连接最长等待 Timeout，开始发送后最长测速 Timeout
+func (s *Scanner) uploadHandler(ctx context.Context, ip *net.IPAddr) float64 {
+	uctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	transport := s.cfg.newTransport(ip)
+	defer closeTransport(transport)
+	if t, ok := transport.(*http.Transport); ok {
+		t.WriteBufferSize = uploadBufferSize
+	}
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // 上传数据无法重新发送，不跟随重定向
+		},
+	}
+	payload := newUploadPayload(s.cfg.UploadSize)
+	req, err := s.cfg.newRequest(uctx, http.MethodPost, s.cfg.UploadURL, payload)
+	if err != nil {
+		if s.cfg.Debug {
+			utils.Red.Printf("[调试] IP: %s, 上传测速请求创建失败，错误信息: %v, 上传测速地址: %s\n", ip.String(), err, s.cfg.UploadURL)
+		}
+		return 0.0
+	}
+	req.ContentLength = s.cfg.UploadSize
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	done := make(chan error, 1) // 请求完成（服务器已响应或出错）
+	go func() {
+		response, err := client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+			if response.StatusCode < 200 || response.StatusCode > 299 {
+				err = &uploadStatusError{response.StatusCode}
+			}
+		}
+		done <- err
+	}()
+
+	// 等待开始发送数据
+	timer := time.NewTimer(s.cfg.Timeout)
+	defer timer.Stop()
+	select {
+	case <-payload.started:
+	case err = <-done:
+		s.printUploadDebugInfo(ip, err)
+		return 0.0
+	case <-timer.C:
+		s.printUploadDebugInfo(ip, context.DeadlineExceeded)
+		return 0.0
+	}
+
+	timeSlice := s.cfg.Timeout / 100
+	timer.Reset(s.cfg.Timeout)
+	ticker := time.NewTicker(timeSlice)
+	defer ticker.Stop()
+	e := ewma.NewMovingAverage() // 使用 EWMA 算法计算平均速度
+	var lastSent int64
+	lastTick := time.Now()
+
+	// 每个时间片统计一次已发送的数据量
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			sent := payload.sent.total()
+			e.Add(float64(sent - lastSent))
+			lastSent, lastTick = sent, time.Now()
+		case err = <-done:
+			if err != nil { // 上传出错
+				s.printUploadDebugInfo(ip, err)
+				return 0.0
+			}
+			// 上传完成，计算最后一个时间片的速度
+			if elapsed := time.Since(lastTick); elapsed > 0 {
+				e.Add(float64(payload.sent.total()-lastSent) / (float64(elapsed) / float64(timeSlice)))
+			}
+			break loop
+		case <-timer.C: // 超时则退出
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	// 返回上传速度
+	return e.Value() / (s.cfg.Timeout.Seconds() / 120)
+}
+
+// uploadStatusError 上传测速的 HTTP 状态码不是 2xx
+type uploadStatusError struct {
+	statusCode int
+}
+
+func (e *uploadStatusError) Error() string {
+	return "HTTP 状态码: " + strconv.Itoa(e.statusCode)
+}
+
+// printUploadDebugInfo 输出上传测速的调试信息
+func (s *Scanner) printUploadDebugInfo(ip *net.IPAddr, err error) {
+	if s.cfg.Debug {
+		utils.Red.Printf("[调试] IP: %s, 上传测速失败，错误信息: %v, 上传测速地址: %s\n", ip.String(), err, s.cfg.UploadURL)
+	}
+}
@@ -0,0 +1,104 @@
+package task
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// uploadServer 启动本地上传测速服务器，返回指向该服务器的配置及已接收的字节数
+func uploadServer(t *testing.T, status int) (Config, *int64) {
+	t.Helper()
+	var received int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		n, _ := io.Copy(io.Discard, r.Body)
+		atomic.AddInt64(&received, n)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.UploadURL = srv.URL + "/upload"
+	cfg.UploadSize = 2 * 1024 * 1024
+	cfg.TCPPort = port
+	cfg.Timeout = time.Second
+	return cfg, &received
+}
+
+// TestCheckUploadDefault 测试上传数据量的默认值及上限（与配置文件的取值范围一致）
+func TestCheckUploadDefault(t *testing.T) {
+	for _, tt := range []struct{ size, want int64 }{
+		{0, defaultUploadSize},
+		{2 * 1024 * 1024, 2 * 1024 * 1024},
+		{999999 * 1024 * 1024, maxUploadSize},
+	} {
+		cfg := DefaultConfig()
+		cfg.UploadSize = tt.size
+		if got := NewScanner(cfg).Config().UploadSize; got != tt.want {
+			t.Errorf("UploadSize(%d) = %d, expected %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+// TestUploadPayload 测试上传数据的长度及发送统计
+func TestUploadPayload(t *testing.T) {
+	p := newUploadPayload(100000)
+	n, err := io.Copy(io.Discard, p)
+	if err != nil || n != 100000 {
+		t.Fatalf("io.Copy() = %d, %v, expected 100000", n, err)
+	}
+	if p.sent.total() != 100000 {
+		t.Errorf("sent = %d, expected 100000", p.sent.total())
+	}
+	select {
+	case <-p.started:
+	default:
+		t.Error("started should be closed after the first read")
+	}
+}
+
+// TestTestUploadSpeed 测试上传测速：只测试前 TestCount 个 IP
+func TestTestUploadSpeed(t *testing.T) {
+	cfg, received := uploadServer(t, http.StatusOK)
+	cfg.TestCount = 2
+	ipSet := make(utils.DownloadSpeedSet, 0, 3)
+	for i := 0; i < 3; i++ {
+		ipSet = append(ipSet, utils.CloudflareIPData{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("127.0.0.1")}, Sended: 4, Received: 4}})
+	}
+
+	NewScanner(cfg).TestUploadSpeed(context.Background(), ipSet)
+
+	if got := atomic.LoadInt64(received); got != 2*cfg.UploadSize {
+		t.Errorf("received = %d, expected %d", got, 2*cfg.UploadSize)
+	}
+	if ipSet[0].UploadSpeed <= 0 || ipSet[1].UploadSpeed <= 0 {
+		t.Errorf("UploadSpeed = %f, %f, expected > 0", ipSet[0].UploadSpeed, ipSet[1].UploadSpeed)
+	}
+	if ipSet[2].UploadSpeed != 0 {
+		t.Errorf("UploadSpeed of untested IP = %f, expected 0", ipSet[2].UploadSpeed)
+	}
+}
+
+// TestUploadHandler_BadStatus 测试服务器返回非 2xx 状态码时上传速度为 0
+func TestUploadHandler_BadStatus(t *testing.T) {
+	cfg, _ := uploadServer(t, http.StatusForbidden)
+	speed := NewScanner(cfg).uploadHandler(context.Background(), &net.IPAddr{IP: net.ParseIP("127.0.0.1")})
+	if speed != 0 {
+		t.Errorf("speed = %f, expected 0", speed)
+	}
+}
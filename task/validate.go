@@ -0,0 +1,88 @@
+package task
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// 校验响应体时最多读取的数据量
+const maxCheckBodySize = 16 * 1024 * 1024
+
+// ResponseCheck HTTPing 响应内容校验规则，未通过校验的请求视为失败
+// 用于排除返回了验证页面、错误页面等非预期内容的 IP
+type ResponseCheck struct {
+	Contains string         // 响应体需包含的字符串
+	Regexp   *regexp.Regexp // 响应体需匹配的正则表达式
+	Header   http.Header    // 响应头需包含的值（值为子串匹配）
+	MinSize  int64          // 响应体最小字节数
+	SHA256   string         // 响应体的 SHA-256（小写十六进制）
+}
+
+// Enabled 返回是否指定了任一校验规则
+func (c *ResponseCheck) Enabled() bool {
+	return len(c.Header) > 0 || c.needBody()
+}
+
+// needBody 返回校验是否需要读取响应体
+func (c *ResponseCheck) needBody() bool {
+	return c.Contains != "" || c.Regexp != nil || c.MinSize > 0 || c.SHA256 != ""
+}
+
+// check 校验响应头及响应体，读取完响应体后返回第一个未通过的规则
+func (c *ResponseCheck) check(response *http.Response) error {
+	for name, values := range c.Header {
+		got := response.Header.Get(name)
+		for _, want := range values {
+			if !strings.Contains(got, want) {
+				return fmt.Errorf("响应头 %s 为 %q，不包含 %q", name, got, want)
+			}
+		}
+	}
+	if !c.needBody() {
+		_, err := io.Copy(io.Discard, response.Body)
+		return err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxCheckBodySize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxCheckBodySize {
+		if c.Contains != "" || c.Regexp != nil || c.SHA256 != "" {
+			return fmt.Errorf("响应体超过 %d MB，无法校验", maxCheckBodySize/1024/1024)
+		}
+		_, err = io.Copy(io.Discard, response.Body) // 仅校验大小时已满足要求
+		return err
+	}
+	if int64(len(body)) < c.MinSize {
+		return fmt.Errorf("响应体大小 %d 字节，小于 %d 字节", len(body), c.MinSize)
+	}
+	if c.Contains != "" && !bytes.Contains(body, []byte(c.Contains)) {
+		return fmt.Errorf("响应体不包含 %q", c.Contains)
+	}
+	if c.Regexp != nil && !c.Regexp.Match(body) {
+		return fmt.Errorf("响应体不匹配正则表达式 %s", c.Regexp)
+	}
+	if c.SHA256 != "" {
+		sum := sha256.Sum256(body)
+		if got := hex.EncodeToString(sum[:]); got != c.SHA256 {
+			return fmt.Errorf("响应体 SHA-256 为 %s，与 %s 不一致", got, c.SHA256)
+		}
+	}
+	return nil
+}
+
+// ParseSHA256 解析十六进制的 SHA-256 值，返回小写形式
+func ParseSHA256(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("%q 不是有效的 SHA-256 值（64 位十六进制）", s)
+	}
+	return s, nil
+}
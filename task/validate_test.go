@@ -0,0 +1,92 @@
+package task
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/XIU2/CloudflareSpeedTest/utils"
+)
+
+// TestResponseCheck 测试各项响应内容校验规则
+func TestResponseCheck(t *testing.T) {
+	const body = "<html><title>Example</title></html>"
+	const sha = "384977231a8233c8f18bbbcf383924a223f02e6c17f0a6a8f2ef87ae4092dd27"
+	tests := []struct {
+		name  string
+		check ResponseCheck
+		ok    bool
+	}{
+		{"empty", ResponseCheck{}, true},
+		{"contains", ResponseCheck{Contains: "Example"}, true},
+		{"contains mismatch", ResponseCheck{Contains: "Just a moment"}, false},
+		{"regexp", ResponseCheck{Regexp: regexp.MustCompile(`(?i)<TITLE>`)}, true},
+		{"regexp mismatch", ResponseCheck{Regexp: regexp.MustCompile(`^\{`)}, false},
+		{"header", ResponseCheck{Header: http.Header{"Content-Type": {"text/html"}}}, true},
+		{"header mismatch", ResponseCheck{Header: http.Header{"Content-Type": {"image/svg"}}}, false},
+		{"min size", ResponseCheck{MinSize: int64(len(body))}, true},
+		{"min size mismatch", ResponseCheck{MinSize: int64(len(body)) + 1}, false},
+		{"sha256", ResponseCheck{SHA256: sha}, true},
+		{"sha256 mismatch", ResponseCheck{SHA256: strings.Repeat("0", 64)}, false},
+	}
+	for _, tt := range tests {
+		response := &http.Response{
+			Header: http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			Body:   io.NopCloser(strings.NewReader(body)),
+		}
+		if err := tt.check.check(response); (err == nil) != tt.ok {
+			t.Errorf("%s: check() error = %v, expected ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// TestParseSHA256 测试解析 SHA-256 值
+func TestParseSHA256(t *testing.T) {
+	got, err := ParseSHA256(" E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855 ")
+	if err != nil || got != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("ParseSHA256() = %q, %v", got, err)
+	}
+	for _, s := range []string{"", "abc", strings.Repeat("z", 64)} {
+		if _, err := ParseSHA256(s); err == nil {
+			t.Errorf("ParseSHA256(%q) expected error", s)
+		}
+	}
+}
+
+// TestHttping_ResponseCheck 测试 HTTPing 返回非预期内容的 IP 视为不可用，需要校验响应体时 HEAD 请求改为 GET
+func TestHttping_ResponseCheck(t *testing.T) {
+	var method string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		_, _ = w.Write([]byte("<title>Just a moment...</title>"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	cfg := DefaultConfig()
+	cfg.URL = srv.URL
+	cfg.TCPPort = port
+	cfg.Httping = true
+	cfg.HttpingCheck.Contains = "Example"
+	cfg = NewScanner(cfg).Config()
+	p := &Ping{cfg: &cfg}
+
+	data := &utils.PingData{IP: &net.IPAddr{IP: net.ParseIP("127.0.0.1")}}
+	p.httping(context.Background(), data)
+	delays := data.Samples
+
+	if method != http.MethodGet {
+		t.Errorf("method = %s, expected GET", method)
+	}
+	if len(delays) != 0 {
+		t.Errorf("expected IP to be rejected, got %d delays", len(delays))
+	}
+}
@@ -1,39 +1,26 @@
 package utils
 
 import (
-	"encoding/csv"
 	"fmt"
-	"log"
 	"net"
-	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	defaultOutput         = "result.csv"
-	maxDelay              = 9999 * time.Millisecond
-	minDelay              = 0 * time.Millisecond
-	maxLossRate   float32 = 1.0
+	DefaultOutput              = "result.csv"
+	DefaultPrintNum            = 10
+	DefaultMaxDelay            = 9999 * time.Millisecond
+	DefaultMinDelay            = 0 * time.Millisecond
+	DefaultMaxLossRate float32 = 1.0
+	DefaultMaxJitter           = 9999 * time.Millisecond
 )
 
-var (
-	InputMaxDelay    = maxDelay
-	InputMinDelay    = minDelay
-	InputMaxLossRate = maxLossRate
-	Output           = defaultOutput
-	PrintNum         = 10
-	Debug            = false // 是否开启调试模式
-)
-
-// 是否打印测试结果
-func NoPrintResult() bool {
-	return PrintNum == 0
-}
-
 // 是否输出到文件
-func noOutput() bool {
-	return Output == "" || Output == " "
+func noOutput(output string) bool {
+	return output == "" || output == " "
 }
 
 type PingData struct {
@@ -42,25 +29,85 @@ type PingData struct {
 	Received int
 	Delay    time.Duration
 	Colo     string
+	Samples  []time.Duration // 每次成功测速的延迟（按测速顺序）
+	TLS      *TLSInfo        // TLS 握手信息（仅 TLSPing 模式）
+	Proto    string          // 使用的 HTTP 协议版本，如 HTTP/2.0（仅 HTTPing 或下载测速时记录）
+	Rejected StatusTally     // 被拒绝的 HTTP 状态码及次数（仅 HTTPing 或下载测速时记录）
+
+	stats *DelayStats // 延迟统计（首次使用时计算）
+}
+
+// TLSInfo TLSPing 模式下单个 IP 的 TLS 握手信息
+type TLSInfo struct {
+	Connect   time.Duration // 平均 TCP 连接耗时
+	Handshake time.Duration // 平均 TLS 握手耗时
+	Version   string        // 协商的 TLS 版本，如 TLS 1.3
+	ALPN      string        // 协商的应用层协议，如 h2
+	CertValid bool          // 证书对测速地址的域名是否有效
+	CertError string        // 证书无效的原因
+}
+
+// StatusTally 被拒绝的 HTTP 状态码及次数
+type StatusTally map[int]int
+
+// Add 记录一次被拒绝的状态码
+func (t *StatusTally) Add(code int) {
+	if *t == nil {
+		*t = make(StatusTally)
+	}
+	(*t)[code]++
+}
+
+// Merge 合并另一份统计
+func (t *StatusTally) Merge(other StatusTally) {
+	for code, n := range other {
+		if *t == nil {
+			*t = make(StatusTally)
+		}
+		(*t)[code] += n
+	}
+}
+
+// String 按状态码从小到大输出，如 403x2 503x1
+func (t StatusTally) String() string {
+	codes := make([]int, 0, len(t))
+	for code := range t {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	items := make([]string, len(codes))
+	for i, code := range codes {
+		items[i] = strconv.Itoa(code) + "x" + strconv.Itoa(t[code])
+	}
+	return strings.Join(items, " ")
 }
 
 type CloudflareIPData struct {
 	*PingData
-	lossRate      float32
 	DownloadSpeed float64
+	Downloaded    bool    // 是否进行了下载测速（未测速时 DownloadSpeed 为 0 不代表下载失败）
+	SingleSpeed   float64 // 单连接下载速度（多连接下载测速时为最快的连接在共享带宽时的速度，DownloadSpeed 为总速度）
+	UploadSpeed   float64 // 上传速度
 }
 
-// 计算丢包率
+// 计算丢包率（不缓存结果，以便多个 goroutine 同时读取同一测速结果）
 func (cf *CloudflareIPData) getLossRate() float32 {
-	if cf.lossRate == 0 {
-		pingLost := cf.Sended - cf.Received
-		cf.lossRate = float32(pingLost) / float32(cf.Sended)
-	}
-	return cf.lossRate
+	pingLost := cf.Sended - cf.Received
+	return float32(pingLost) / float32(cf.Sended)
+}
+
+// LossRate 返回丢包率
+func (cf *CloudflareIPData) LossRate() float32 {
+	return cf.getLossRate()
+}
+
+// 毫秒，保留两位小数
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds()*1000, 'f', 2, 32)
 }
 
 func (cf *CloudflareIPData) toString() []string {
-	result := make([]string, 7)
+	result := make([]string, 22)
 	result[0] = cf.IP.String()
 	result[1] = strconv.Itoa(cf.Sended)
 	result[2] = strconv.Itoa(cf.Received)
@@ -73,23 +120,30 @@ func (cf *CloudflareIPData) toString() []string {
 	} else {
 		result[6] = cf.Colo
 	}
+	stats := cf.Stats()
+	result[7] = formatMs(stats.Min)
+	result[8] = formatMs(stats.Max)
+	result[9] = formatMs(stats.StdDev)
+	result[10] = formatMs(stats.P50)
+	result[11] = formatMs(stats.P90)
+	result[12] = formatMs(stats.Jitter)
+	result[13] = strconv.FormatFloat(cf.SingleSpeed/1024/1024, 'f', 2, 32)
+	result[14] = strconv.FormatFloat(cf.UploadSpeed/1024/1024, 'f', 2, 32)
+	if cf.TLS != nil { // 非 TLSPing 模式时留空
+		result[15] = formatMs(cf.TLS.Connect)
+		result[16] = formatMs(cf.TLS.Handshake)
+		result[17] = cf.TLS.Version
+		result[18] = cf.TLS.ALPN
+		result[19] = strconv.FormatBool(cf.TLS.CertValid)
+	}
+	result[20] = cf.Proto
+	result[21] = cf.Rejected.String()
 	return result
 }
 
-func ExportCsv(data []CloudflareIPData) {
-	if noOutput() || len(data) == 0 {
-		return
-	}
-	fp, err := os.Create(Output)
-	if err != nil {
-		log.Fatalf("创建文件[%s]失败：%v", Output, err)
-		return
-	}
-	defer fp.Close()
-	w := csv.NewWriter(fp) //创建一个新的写入文件流
-	_ = w.Write([]string{"IP 地址", "已发送", "已接收", "丢包率", "平均延迟", "下载速度(MB/s)", "地区码"})
-	_ = w.WriteAll(convertToString(data))
-	w.Flush()
+// ExportCsv 将测速结果写入 CSV 文件
+func ExportCsv(output string, data []CloudflareIPData) error {
+	return Export(output, FormatCSV, ExportMeta{}, data)
 }
 
 func convertToString(data []CloudflareIPData) [][]string {
@@ -104,18 +158,18 @@ func convertToString(data []CloudflareIPData) [][]string {
 type PingDelaySet []CloudflareIPData
 
 // FilterDelay 按延迟条件过滤 IP
-func (s PingDelaySet) FilterDelay() (data PingDelaySet) {
-	if InputMaxDelay > maxDelay || InputMinDelay < minDelay {
+func (s PingDelaySet) FilterDelay(minDelay, maxDelay time.Duration) (data PingDelaySet) {
+	if maxDelay > DefaultMaxDelay || minDelay < DefaultMinDelay {
 		return s
 	}
-	if InputMaxDelay == maxDelay && InputMinDelay == minDelay {
+	if maxDelay == DefaultMaxDelay && minDelay == DefaultMinDelay {
 		return s
 	}
 	for _, v := range s {
-		if v.Delay > InputMaxDelay {
+		if v.Delay > maxDelay {
 			break // 超出上限，后面的也都不满足
 		}
-		if v.Delay < InputMinDelay {
+		if v.Delay < minDelay {
 			continue // 低于下限，跳过
 		}
 		data = append(data, v)
@@ -124,12 +178,12 @@ func (s PingDelaySet) FilterDelay() (data PingDelaySet) {
 }
 
 // FilterLossRate 按丢包率条件过滤 IP
-func (s PingDelaySet) FilterLossRate() (data PingDelaySet) {
-	if InputMaxLossRate >= maxLossRate {
+func (s PingDelaySet) FilterLossRate(maxLossRate float32) (data PingDelaySet) {
+	if maxLossRate >= DefaultMaxLossRate {
 		return s
 	}
 	for _, v := range s {
-		if v.getLossRate() > InputMaxLossRate {
+		if v.getLossRate() > maxLossRate {
 			break // 超出上限，后面的也都不满足
 		}
 		data = append(data, v)
@@ -137,16 +191,25 @@ func (s PingDelaySet) FilterLossRate() (data PingDelaySet) {
 	return
 }
 
+// FilterJitter 按抖动上限过滤 IP
+func (s PingDelaySet) FilterJitter(maxJitter time.Duration) (data PingDelaySet) {
+	if maxJitter <= 0 || maxJitter >= DefaultMaxJitter {
+		return s
+	}
+	for _, v := range s {
+		if v.Stats().Jitter <= maxJitter {
+			data = append(data, v)
+		}
+	}
+	return
+}
+
 // 实现 sort.Interface 接口
 func (s PingDelaySet) Len() int {
 	return len(s)
 }
 func (s PingDelaySet) Less(i, j int) bool {
-	iRate, jRate := s[i].getLossRate(), s[j].getLossRate()
-	if iRate != jRate {
-		return iRate < jRate // 丢包率低的优先
-	}
-	return s[i].Delay < s[j].Delay // 延迟低的优先
+	return DefaultPingOrder.Less(&s[i], &s[j]) // 丢包率低的优先，其次延迟低的优先
 }
 func (s PingDelaySet) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
@@ -160,14 +223,15 @@ func (s DownloadSpeedSet) Len() int {
 	return len(s)
 }
 func (s DownloadSpeedSet) Less(i, j int) bool {
-	return s[i].DownloadSpeed > s[j].DownloadSpeed // 速度高的优先
+	return DefaultSpeedOrder.Less(&s[i], &s[j]) // 速度高的优先
 }
 func (s DownloadSpeedSet) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
-func (s DownloadSpeedSet) Print() {
-	if NoPrintResult() {
+// Print 打印前 printNum 个测速结果，output 为已写入的结果文件
+func (s DownloadSpeedSet) Print(printNum int, output string) {
+	if printNum == 0 {
 		return
 	}
 	if len(s) <= 0 {
@@ -175,13 +239,13 @@ func (s DownloadSpeedSet) Print() {
 		return
 	}
 	dateString := convertToString(s)
-	if len(dateString) < PrintNum {
-		PrintNum = len(dateString)
+	if len(dateString) < printNum {
+		printNum = len(dateString)
 	}
 	headFormat := "%-16s%-5s%-5s%-5s%-6s%-12s%-5s\n"
 	dataFormat := "%-18s%-8s%-8s%-8s%-10s%-16s%-8s\n"
 	// IPv6 地址较长时调整格式
-	for i := 0; i < PrintNum; i++ {
+	for i := 0; i < printNum; i++ {
 		if len(dateString[i][0]) > 15 {
 			headFormat = "%-40s%-5s%-5s%-5s%-6s%-12s%-5s\n"
 			dataFormat = "%-42s%-8s%-8s%-8s%-10s%-16s%-8s\n"
@@ -189,10 +253,10 @@ func (s DownloadSpeedSet) Print() {
 		}
 	}
 	Cyan.Printf(headFormat, "IP 地址", "已发送", "已接收", "丢包率", "平均延迟", "下载速度(MB/s)", "地区码")
-	for i := 0; i < PrintNum; i++ {
+	for i := 0; i < printNum; i++ {
 		fmt.Printf(dataFormat, dateString[i][0], dateString[i][1], dateString[i][2], dateString[i][3], dateString[i][4], dateString[i][5], dateString[i][6])
 	}
-	if !noOutput() {
-		fmt.Printf("\n完整测速结果已写入 %v 文件，可使用记事本/表格软件查看。\n", Output)
+	if !noOutput(output) {
+		fmt.Printf("\n完整测速结果已写入 %v 文件，可使用记事本/表格软件查看。\n", output)
 	}
 }
@@ -72,16 +72,9 @@ func TestPingDelaySet_FilterDelay(t *testing.T) {
 	ip2 := net.ParseIP("2.2.2.2")
 	ip3 := net.ParseIP("3.3.3.3")
 
-	originalMaxDelay := InputMaxDelay
-	originalMinDelay := InputMinDelay
-	defer func() {
-		InputMaxDelay = originalMaxDelay
-		InputMinDelay = originalMinDelay
-	}()
-
 	// 设置延迟范围 100ms - 200ms
-	InputMaxDelay = 200 * time.Millisecond
-	InputMinDelay = 100 * time.Millisecond
+	maxDelay := 200 * time.Millisecond
+	minDelay := 100 * time.Millisecond
 
 	data := PingDelaySet{
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip1}, Delay: 50 * time.Millisecond}},  // 低于最小值
@@ -89,7 +82,7 @@ func TestPingDelaySet_FilterDelay(t *testing.T) {
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip3}, Delay: 300 * time.Millisecond}}, // 高于最大值
 	}
 
-	filtered := data.FilterDelay()
+	filtered := data.FilterDelay(minDelay, maxDelay)
 
 	if len(filtered) != 1 {
 		t.Errorf("expected 1 result, got %d", len(filtered))
@@ -106,13 +99,8 @@ func TestPingDelaySet_FilterLossRate(t *testing.T) {
 	ip2 := net.ParseIP("2.2.2.2")
 	ip3 := net.ParseIP("3.3.3.3")
 
-	originalMaxLossRate := InputMaxLossRate
-	defer func() {
-		InputMaxLossRate = originalMaxLossRate
-	}()
-
 	// 设置最大丢包率为 0.25 (25%)
-	InputMaxLossRate = 0.25
+	var maxLossRate float32 = 0.25
 
 	data := PingDelaySet{
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip1}, Sended: 4, Received: 4}}, // 0% 丢包
@@ -120,7 +108,7 @@ func TestPingDelaySet_FilterLossRate(t *testing.T) {
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip3}, Sended: 4, Received: 2}}, // 50% 丢包
 	}
 
-	filtered := data.FilterLossRate()
+	filtered := data.FilterLossRate(maxLossRate)
 
 	if len(filtered) != 2 {
 		t.Errorf("expected 2 results, got %d", len(filtered))
@@ -221,26 +209,15 @@ func TestPingData_toString_NAColo(t *testing.T) {
 	}
 }
 
-// TestNoPrintResult 测试是否打印结果的判断逻辑
-// 当 PrintNum 为 0 时，不打印结果
-func TestNoPrintResult(t *testing.T) {
-	tests := []struct {
-		name     string
-		printNum int
-		expected bool
-	}{
-		{"不打印", 0, true},
-		{"打印", 10, false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			PrintNum = tt.printNum
-			if got := NoPrintResult(); got != tt.expected {
-				t.Errorf("NoPrintResult() = %v, expected %v", got, tt.expected)
-			}
-		})
+// TestDownloadSpeedSet_PrintNone 测试不打印结果
+// 当 printNum 为 0 时，不打印结果
+func TestDownloadSpeedSet_PrintNone(t *testing.T) {
+	data := DownloadSpeedSet{
+		{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4}},
 	}
+	// 应该不 panic
+	data.Print(0, "")
+	data.Print(10, "")
 }
 
 // TestNoOutput 测试是否输出到文件的判断逻辑
@@ -258,8 +235,7 @@ func TestNoOutput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			Output = tt.output
-			if got := noOutput(); got != tt.want {
+			if got := noOutput(tt.output); got != tt.want {
 				t.Errorf("noOutput() = %v, expected %v", got, tt.want)
 			}
 		})
@@ -277,15 +253,10 @@ func TestExportCsv_SkipEmptyData(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			originalOutput := Output
-			Output = "test_output.csv"
-			defer func() {
-				Output = originalOutput
-				os.Remove("test_output.csv")
-			}()
+			defer os.Remove("test_output.csv")
 
 			// 不应该 panic 且不应该创建文件
-			ExportCsv(tt.data)
+			ExportCsv("test_output.csv", tt.data)
 		})
 	}
 }
@@ -295,23 +266,16 @@ func TestFilterDelay_DefaultRange(t *testing.T) {
 	ip1 := net.ParseIP("1.1.1.1")
 	ip2 := net.ParseIP("2.2.2.2")
 
-	originalMaxDelay := InputMaxDelay
-	originalMinDelay := InputMinDelay
-	defer func() {
-		InputMaxDelay = originalMaxDelay
-		InputMinDelay = originalMinDelay
-	}()
-
 	// 使用默认范围
-	InputMaxDelay = 9999 * time.Millisecond
-	InputMinDelay = 0
+	maxDelay := 9999 * time.Millisecond
+	minDelay := time.Duration(0)
 
 	data := PingDelaySet{
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip1}, Delay: 50 * time.Millisecond}},
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip2}, Delay: 500 * time.Millisecond}},
 	}
 
-	filtered := data.FilterDelay()
+	filtered := data.FilterDelay(minDelay, maxDelay)
 
 	// 默认范围应该返回所有数据
 	if len(filtered) != 2 {
@@ -324,20 +288,15 @@ func TestFilterLossRate_DefaultRate(t *testing.T) {
 	ip1 := net.ParseIP("1.1.1.1")
 	ip2 := net.ParseIP("2.2.2.2")
 
-	originalMaxLossRate := InputMaxLossRate
-	defer func() {
-		InputMaxLossRate = originalMaxLossRate
-	}()
-
 	// 使用默认最大丢包率
-	InputMaxLossRate = 1.0
+	var maxLossRate float32 = 1.0
 
 	data := PingDelaySet{
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip1}, Sended: 4, Received: 4}}, // 0% 丢包
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip2}, Sended: 4, Received: 0}}, // 100% 丢包
 	}
 
-	filtered := data.FilterLossRate()
+	filtered := data.FilterLossRate(maxLossRate)
 
 	// 默认丢包率应该返回所有数据
 	if len(filtered) != 2 {
@@ -345,8 +304,8 @@ func TestFilterLossRate_DefaultRate(t *testing.T) {
 	}
 }
 
-// TestPingData_LossRateCaching 测试丢包率缓存功能
-func TestPingData_LossRateCaching(t *testing.T) {
+// TestPingData_LossRateRecompute 测试丢包率每次按当前收发次数计算（不缓存）
+func TestPingData_LossRateRecompute(t *testing.T) {
 	data := &CloudflareIPData{
 		PingData: &PingData{
 			Sended:   4,
@@ -354,13 +313,13 @@ func TestPingData_LossRateCaching(t *testing.T) {
 		},
 	}
 
-	// 第一次计算
-	rate1 := data.getLossRate()
-	// 第二次计算应该使用缓存
-	rate2 := data.getLossRate()
-
-	if rate1 != rate2 {
-		t.Errorf("loss rate should be cached, got %v and %v", rate1, rate2)
+	if rate := data.getLossRate(); rate != 0.25 {
+		t.Errorf("loss rate = %v, expected 0.25", rate)
+	}
+	// 收到的次数变化后应重新计算
+	data.Received = 4
+	if rate := data.getLossRate(); rate != 0 {
+		t.Errorf("loss rate after Received changed = %v, expected 0", rate)
 	}
 }
 
@@ -397,15 +356,8 @@ func TestFilterDelay_OutOfOrder(t *testing.T) {
 	ip3 := net.ParseIP("3.3.3.3")
 	ip4 := net.ParseIP("4.4.4.4")
 
-	originalMaxDelay := InputMaxDelay
-	originalMinDelay := InputMinDelay
-	defer func() {
-		InputMaxDelay = originalMaxDelay
-		InputMinDelay = originalMinDelay
-	}()
-
-	InputMaxDelay = 200 * time.Millisecond
-	InputMinDelay = 50 * time.Millisecond
+	maxDelay := 200 * time.Millisecond
+	minDelay := 50 * time.Millisecond
 
 	// 先按延迟排序的数据
 	data := PingDelaySet{
@@ -415,7 +367,7 @@ func TestFilterDelay_OutOfOrder(t *testing.T) {
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip4}, Delay: 400 * time.Millisecond}}, // 不会被处理
 	}
 
-	filtered := data.FilterDelay()
+	filtered := data.FilterDelay(minDelay, maxDelay)
 
 	// FilterDelay 会在遇到超范围值后停止，所以只有前2个
 	if len(filtered) != 2 {
@@ -530,8 +482,8 @@ func TestConvertToString(t *testing.T) {
 	if len(result) != 2 {
 		t.Errorf("expected 2 rows, got %d", len(result))
 	}
-	if len(result[0]) != 7 {
-		t.Errorf("expected 7 columns, got %d", len(result[0]))
+	if len(result[0]) != 22 {
+		t.Errorf("expected 22 columns, got %d", len(result[0]))
 	}
 	if result[0][0] != "1.1.1.1" {
 		t.Errorf("expected first IP 1.1.1.1, got %s", result[0][0])
@@ -547,16 +499,9 @@ func TestFilterDelay_ExactBoundary(t *testing.T) {
 	ip2 := net.ParseIP("2.2.2.2")
 	ip3 := net.ParseIP("3.3.3.3")
 
-	originalMaxDelay := InputMaxDelay
-	originalMinDelay := InputMinDelay
-	defer func() {
-		InputMaxDelay = originalMaxDelay
-		InputMinDelay = originalMinDelay
-	}()
-
 	// 精确边界测试
-	InputMaxDelay = 200 * time.Millisecond
-	InputMinDelay = 100 * time.Millisecond
+	maxDelay := 200 * time.Millisecond
+	minDelay := 100 * time.Millisecond
 
 	data := PingDelaySet{
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip1}, Delay: 100 * time.Millisecond}}, // 等于最小值
@@ -564,7 +509,7 @@ func TestFilterDelay_ExactBoundary(t *testing.T) {
 		{PingData: &PingData{IP: &net.IPAddr{IP: ip3}, Delay: 50 * time.Millisecond}},  // 小于最小值
 	}
 
-	filtered := data.FilterDelay()
+	filtered := data.FilterDelay(minDelay, maxDelay)
 
 	// 边界值应该被包含
 	if len(filtered) != 2 {
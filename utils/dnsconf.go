@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DNS 配置文件格式（将域名指向测速结果的前 N 个 IP）
+const (
+	FormatDnsmasq = "dnsmasq" // dnsmasq address 配置
+	FormatUnbound = "unbound" // unbound local-data 配置
+	FormatAdGuard = "adguard" // AdGuard Home DNS 重写规则列表
+
+	dnsRecordTTL = 300 // unbound 记录 TTL（秒）
+)
+
+// dnsAnswer 单个域名的解析结果
+type dnsAnswer struct {
+	Type string // A / AAAA
+	IP   string
+}
+
+// dnsAnswers 返回测速结果中前 top 个 IP（top <= 0 时为 1）及其记录类型
+func dnsAnswers(data []CloudflareIPData, top int) []dnsAnswer {
+	if top <= 0 {
+		top = 1
+	}
+	if top > len(data) {
+		top = len(data)
+	}
+	answers := make([]dnsAnswer, 0, top)
+	for _, v := range data[:top] {
+		answer := dnsAnswer{Type: "A", IP: v.IP.String()}
+		if v.IP.IP.To4() == nil {
+			answer.Type = "AAAA"
+		}
+		answers = append(answers, answer)
+	}
+	return answers
+}
+
+// dnsDomains 规范化域名列表（去除空白及末尾的点）
+func dnsDomains(domains []string) []string {
+	result := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if d = strings.TrimSuffix(strings.TrimSpace(d), "."); d != "" {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// validateDomains 检查是否指定了域名
+func validateDomains(meta ExportMeta) error {
+	if len(dnsDomains(meta.Domains)) == 0 {
+		return fmt.Errorf("DNS 配置格式需要指定域名")
+	}
+	return nil
+}
+
+// writeDNSHeader 写入配置文件开头的注释
+func writeDNSHeader(w io.Writer, comment string, meta ExportMeta) error {
+	_, err := fmt.Fprintf(w, "%s 由 CloudflareSpeedTest %s 生成于 %s\n", comment, meta.Version, meta.EndTime.Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// dnsmasqExporter 输出 dnsmasq 配置（address=/域名/IP，同时匹配子域名）
+type dnsmasqExporter struct{}
+
+func (dnsmasqExporter) Validate(meta ExportMeta) error {
+	return validateDomains(meta)
+}
+
+func (dnsmasqExporter) Export(w io.Writer, meta ExportMeta, data []CloudflareIPData) error {
+	if err := validateDomains(meta); err != nil {
+		return err
+	}
+	if err := writeDNSHeader(w, "#", meta); err != nil {
+		return err
+	}
+	answers := dnsAnswers(data, meta.Top)
+	for _, domain := range dnsDomains(meta.Domains) {
+		for _, a := range answers {
+			if _, err := fmt.Fprintf(w, "address=/%s/%s\n", domain, a.IP); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unboundExporter 输出 unbound 配置（redirect 区域，同时匹配子域名）
+type unboundExporter struct{}
+
+func (unboundExporter) Validate(meta ExportMeta) error {
+	return validateDomains(meta)
+}
+
+func (unboundExporter) Export(w io.Writer, meta ExportMeta, data []CloudflareIPData) error {
+	if err := validateDomains(meta); err != nil {
+		return err
+	}
+	if err := writeDNSHeader(w, "#", meta); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "server:"); err != nil {
+		return err
+	}
+	answers := dnsAnswers(data, meta.Top)
+	for _, domain := range dnsDomains(meta.Domains) {
+		if _, err := fmt.Fprintf(w, "    local-zone: \"%s.\" redirect\n", domain); err != nil {
+			return err
+		}
+		for _, a := range answers {
+			if _, err := fmt.Fprintf(w, "    local-data: \"%s. %d IN %s %s\"\n", domain, dnsRecordTTL, a.Type, a.IP); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// adguardExporter 输出 AdGuard Home 的 DNS 重写规则列表（可作为自定义过滤规则或过滤器列表，同时匹配子域名）
+type adguardExporter struct{}
+
+func (adguardExporter) Validate(meta ExportMeta) error {
+	return validateDomains(meta)
+}
+
+func (adguardExporter) Export(w io.Writer, meta ExportMeta, data []CloudflareIPData) error {
+	if err := validateDomains(meta); err != nil {
+		return err
+	}
+	if err := writeDNSHeader(w, "!", meta); err != nil {
+		return err
+	}
+	answers := dnsAnswers(data, meta.Top)
+	for _, domain := range dnsDomains(meta.Domains) {
+		for _, a := range answers {
+			if _, err := fmt.Fprintf(w, "||%s^$dnsrewrite=NOERROR;%s;%s\n", domain, a.Type, a.IP); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
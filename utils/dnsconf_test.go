@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDNSMeta(top int) ExportMeta {
+	return ExportMeta{
+		Version: "v1.0.0",
+		EndTime: time.Date(2025, 1, 2, 3, 4, 5, 0, time.Local),
+		Domains: []string{"a.example.com", " b.example.com. ", ""},
+		Top:     top,
+	}
+}
+
+// dnsBody 去除配置文件开头的注释行
+func dnsBody(s string) string {
+	_, body, _ := strings.Cut(s, "\n")
+	return body
+}
+
+// TestDNSExporters 测试 dnsmasq / unbound / AdGuard 配置生成
+func TestDNSExporters(t *testing.T) {
+	data := testExportData()
+	data = append(data, CloudflareIPData{
+		PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("2606:4700::1")}},
+	})
+
+	tests := []struct {
+		format string
+		top    int
+		want   string
+	}{
+		{FormatDnsmasq, 0, "address=/a.example.com/1.1.1.1\naddress=/b.example.com/1.1.1.1\n"},
+		{FormatDnsmasq, 2, "address=/a.example.com/1.1.1.1\naddress=/a.example.com/2.2.2.2\n" +
+			"address=/b.example.com/1.1.1.1\naddress=/b.example.com/2.2.2.2\n"},
+		{FormatUnbound, 1, "server:\n" +
+			"    local-zone: \"a.example.com.\" redirect\n" +
+			"    local-data: \"a.example.com. 300 IN A 1.1.1.1\"\n" +
+			"    local-zone: \"b.example.com.\" redirect\n" +
+			"    local-data: \"b.example.com. 300 IN A 1.1.1.1\"\n"},
+		{FormatAdGuard, 10, "||a.example.com^$dnsrewrite=NOERROR;A;1.1.1.1\n" +
+			"||a.example.com^$dnsrewrite=NOERROR;A;2.2.2.2\n" +
+			"||a.example.com^$dnsrewrite=NOERROR;AAAA;2606:4700::1\n" +
+			"||b.example.com^$dnsrewrite=NOERROR;A;1.1.1.1\n" +
+			"||b.example.com^$dnsrewrite=NOERROR;A;2.2.2.2\n" +
+			"||b.example.com^$dnsrewrite=NOERROR;AAAA;2606:4700::1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := exporters[tt.format].Export(&buf, testDNSMeta(tt.top), data); err != nil {
+				t.Fatalf("Export error: %v", err)
+			}
+			if !strings.Contains(buf.String(), "CloudflareSpeedTest v1.0.0 生成于 2025-01-02 03:04:05") {
+				t.Errorf("missing header comment: %q", buf.String())
+			}
+			if got := dnsBody(buf.String()); got != tt.want {
+				t.Errorf("got:\n%s\nexpected:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateExport 测试测速前的结果文件格式检查
+func TestValidateExport(t *testing.T) {
+	if err := ValidateExport("result.csv", "", ExportMeta{}); err != nil {
+		t.Errorf("csv: unexpected error %v", err)
+	}
+	if err := ValidateExport("result.txt", "xml", ExportMeta{}); err == nil {
+		t.Error("xml: expected unsupported format error")
+	}
+	if err := ValidateExport("", "xml", ExportMeta{}); err != nil {
+		t.Errorf("no output: unexpected error %v", err)
+	}
+	for _, format := range []string{FormatDnsmasq, FormatUnbound, FormatAdGuard} {
+		if err := ValidateExport("cloudflare.conf", format, ExportMeta{}); err == nil {
+			t.Errorf("%s: expected error without domains", format)
+		}
+		if err := ValidateExport("cloudflare.conf", format, testDNSMeta(1)); err != nil {
+			t.Errorf("%s: unexpected error %v", format, err)
+		}
+	}
+}
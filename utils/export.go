@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 结果文件格式
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// ExportMeta 结果文件的元数据（仅 JSON 格式输出）
+type ExportMeta struct {
+	Version   string    `json:"version"`    // 程序版本
+	Mode      string    `json:"mode"`       // 延迟测速模式：tcping / httping / tlsping
+	Port      int       `json:"port"`       // 测速端口
+	URL       string    `json:"url"`        // 测速地址
+	StartTime time.Time `json:"start_time"` // 开始测速时间
+	EndTime   time.Time `json:"end_time"`   // 结束测速时间
+	Domains   []string  `json:"-"`          // 需要指向测速结果 IP 的域名（仅 DNS 配置格式使用）
+	Top       int       `json:"-"`          // 写入 DNS 配置的 IP 数量（仅 DNS 配置格式使用）
+}
+
+// IPRecord 单个 IP 的测速结果，字段名称固定，供 JSON/NDJSON 输出使用
+type IPRecord struct {
+	IP            string      `json:"ip"`                       // IP 地址
+	Sent          int         `json:"sent"`                     // 已发送
+	Received      int         `json:"received"`                 // 已接收
+	LossRate      float64     `json:"loss_rate"`                // 丢包率
+	Delay         float64     `json:"delay_ms"`                 // 平均延迟（毫秒）
+	DownloadSpeed float64     `json:"download_speed_mb_s"`      // 下载速度（MB/s）
+	Colo          string      `json:"colo"`                     // 地区码
+	MinDelay      float64     `json:"min_delay_ms"`             // 最小延迟（毫秒）
+	MaxDelay      float64     `json:"max_delay_ms"`             // 最大延迟（毫秒）
+	DelayStdDev   float64     `json:"delay_stddev_ms"`          // 延迟标准差（毫秒）
+	P50Delay      float64     `json:"p50_delay_ms"`             // 延迟中位数（毫秒）
+	P90Delay      float64     `json:"p90_delay_ms"`             // 90% 分位延迟（毫秒）
+	Jitter        float64     `json:"jitter_ms"`                // 抖动（毫秒）
+	SingleSpeed   float64     `json:"single_speed_mb_s"`        // 单连接下载速度（MB/s，多连接时为最快的连接在共享带宽时的速度）
+	UploadSpeed   float64     `json:"upload_speed_mb_s"`        // 上传速度（MB/s）
+	Downloaded    bool        `json:"download_tested"`          // 是否进行了下载测速
+	Protocol      string      `json:"protocol,omitempty"`       // 使用的 HTTP 协议版本
+	RejectedCodes map[int]int `json:"rejected_codes,omitempty"` // 被拒绝的 HTTP 状态码及次数
+	TLS           *TLSRecord  `json:"tls,omitempty"`            // TLS 握手信息（仅 TLSPing 模式）
+}
+
+// TLSRecord TLS 握手信息，供 JSON/NDJSON 输出使用
+type TLSRecord struct {
+	Connect   float64 `json:"connect_ms"`           // 平均 TCP 连接耗时（毫秒）
+	Handshake float64 `json:"handshake_ms"`         // 平均 TLS 握手耗时（毫秒）
+	Version   string  `json:"version"`              // TLS 版本
+	ALPN      string  `json:"alpn"`                 // 应用层协议
+	CertValid bool    `json:"cert_valid"`           // 证书是否有效
+	CertError string  `json:"cert_error,omitempty"` // 证书无效的原因
+}
+
+// Exporter 结果文件导出器
+type Exporter interface {
+	Export(w io.Writer, meta ExportMeta, data []CloudflareIPData) error
+}
+
+// exportValidator 可选接口，导出器在测速开始前检查参数
+type exportValidator interface {
+	Validate(meta ExportMeta) error
+}
+
+// 已注册的导出器，键为格式名称
+var exporters = map[string]Exporter{
+	FormatCSV:     csvExporter{},
+	FormatJSON:    jsonExporter{},
+	FormatNDJSON:  ndjsonExporter{},
+	FormatDnsmasq: dnsmasqExporter{},
+	FormatUnbound: unboundExporter{},
+	FormatAdGuard: adguardExporter{},
+}
+
+// RegisterExporter 注册（或覆盖）指定格式的导出器
+func RegisterExporter(format string, e Exporter) {
+	exporters[strings.ToLower(format)] = e
+}
+
+// ExportFormat 确定结果文件格式：优先使用指定的格式，否则根据文件扩展名判断，默认为 CSV
+func ExportFormat(output, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(filepath.Ext(strings.TrimSpace(output))) {
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return FormatCSV
+}
+
+// ValidateExport 在测速开始前检查结果文件格式及其所需参数
+func ValidateExport(output, format string, meta ExportMeta) error {
+	if noOutput(output) {
+		return nil
+	}
+	format = ExportFormat(output, format)
+	e, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("不支持的结果文件格式[%s]", format)
+	}
+	if v, ok := e.(exportValidator); ok {
+		return v.Validate(meta)
+	}
+	return nil
+}
+
+// Export 按指定格式将测速结果写入文件
+// 格式需在测速开始前通过 ValidateExport 检查，写入失败（如磁盘已满、没有权限）时返回错误，由调用方决定是否继续运行
+func Export(output, format string, meta ExportMeta, data []CloudflareIPData) error {
+	if noOutput(output) || len(data) == 0 {
+		return nil
+	}
+	format = ExportFormat(output, format)
+	if _, ok := exporters[format]; !ok {
+		return fmt.Errorf("不支持的结果文件格式[%s]", format)
+	}
+	fp, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("创建文件[%s]失败：%w", output, err)
+	}
+	if err = ExportTo(fp, format, meta, data); err != nil {
+		fp.Close()
+		return fmt.Errorf("写入文件[%s]失败：%w", output, err)
+	}
+	if err = fp.Close(); err != nil {
+		return fmt.Errorf("写入文件[%s]失败：%w", output, err)
+	}
+	return nil
+}
+
+// ExportTo 按指定格式将测速结果写入 w
+func ExportTo(w io.Writer, format string, meta ExportMeta, data []CloudflareIPData) error {
+	e, ok := exporters[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("不支持的结果文件格式[%s]", format)
+	}
+	return e.Export(w, meta, data)
+}
+
+// 保留两位小数（与 CSV 输出精度一致）
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// 毫秒，保留两位小数
+func roundMs(d time.Duration) float64 {
+	return round2(d.Seconds() * 1000)
+}
+
+// Record 返回单个 IP 的测速结果记录（字段与 JSON/NDJSON 输出一致）
+func (cf *CloudflareIPData) Record() IPRecord {
+	stats := cf.Stats()
+	var tlsRecord *TLSRecord
+	if cf.TLS != nil {
+		tlsRecord = &TLSRecord{
+			Connect:   roundMs(cf.TLS.Connect),
+			Handshake: roundMs(cf.TLS.Handshake),
+			Version:   cf.TLS.Version,
+			ALPN:      cf.TLS.ALPN,
+			CertValid: cf.TLS.CertValid,
+			CertError: cf.TLS.CertError,
+		}
+	}
+	return IPRecord{
+		IP:            cf.IP.String(),
+		Sent:          cf.Sended,
+		Received:      cf.Received,
+		LossRate:      round2(float64(cf.getLossRate())),
+		Delay:         roundMs(cf.Delay),
+		DownloadSpeed: round2(cf.DownloadSpeed / 1024 / 1024),
+		Colo:          cf.Colo,
+		MinDelay:      roundMs(stats.Min),
+		MaxDelay:      roundMs(stats.Max),
+		DelayStdDev:   roundMs(stats.StdDev),
+		P50Delay:      roundMs(stats.P50),
+		P90Delay:      roundMs(stats.P90),
+		Jitter:        roundMs(stats.Jitter),
+		SingleSpeed:   round2(cf.SingleSpeed / 1024 / 1024),
+		UploadSpeed:   round2(cf.UploadSpeed / 1024 / 1024),
+		Downloaded:    cf.Downloaded,
+		Protocol:      cf.Proto,
+		RejectedCodes: cf.Rejected,
+		TLS:           tlsRecord,
+	}
+}
+
+func convertToRecord(data []CloudflareIPData) []IPRecord {
+	result := make([]IPRecord, 0, len(data))
+	for _, v := range data {
+		result = append(result, v.Record())
+	}
+	return result
+}
+
+// csvExporter 输出 CSV 格式（中文表头，与之前的 result.csv 一致）
+type csvExporter struct{}
+
+func (csvExporter) Export(w io.Writer, _ ExportMeta, data []CloudflareIPData) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"IP 地址", "已发送", "已接收", "丢包率", "平均延迟", "下载速度(MB/s)", "地区码", "最小延迟", "最大延迟", "延迟标准差", "P50 延迟", "P90 延迟", "抖动", "单连接速度(MB/s)", "上传速度(MB/s)", "TCP 连接耗时", "TLS 握手耗时", "TLS 版本", "ALPN", "证书有效", "HTTP 协议", "拒绝的状态码"})
+	_ = cw.WriteAll(convertToString(data))
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonExporter 输出 JSON 格式（包含元数据的完整数组）
+type jsonExporter struct{}
+
+func (jsonExporter) Export(w io.Writer, meta ExportMeta, data []CloudflareIPData) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		ExportMeta
+		Count   int        `json:"count"`
+		Results []IPRecord `json:"results"`
+	}{meta, len(data), convertToRecord(data)})
+}
+
+// ndjsonExporter 输出 NDJSON 格式（每行一个 IP 的测速结果）
+type ndjsonExporter struct{}
+
+func (ndjsonExporter) Export(w io.Writer, _ ExportMeta, data []CloudflareIPData) error {
+	enc := json.NewEncoder(w)
+	for _, v := range data {
+		if err := enc.Encode(v.Record()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testExportData() []CloudflareIPData {
+	return []CloudflareIPData{
+		{
+			PingData: &PingData{
+				IP:       &net.IPAddr{IP: net.ParseIP("1.1.1.1")},
+				Sended:   4,
+				Received: 3,
+				Delay:    150 * time.Millisecond,
+				Colo:     "LAX",
+			},
+			DownloadSpeed: 10 * 1024 * 1024,
+		},
+		{
+			PingData: &PingData{
+				IP:       &net.IPAddr{IP: net.ParseIP("2.2.2.2")},
+				Sended:   4,
+				Received: 4,
+				Delay:    200 * time.Millisecond,
+			},
+			DownloadSpeed: 5 * 1024 * 1024,
+		},
+	}
+}
+
+// TestExportFormat 测试结果文件格式判断
+// 优先使用指定格式，否则根据扩展名判断
+func TestExportFormat(t *testing.T) {
+	tests := []struct {
+		output string
+		format string
+		want   string
+	}{
+		{"result.csv", "", FormatCSV},
+		{"result.json", "", FormatJSON},
+		{"result.JSON", "", FormatJSON},
+		{"result.ndjson", "", FormatNDJSON},
+		{"result.jsonl", "", FormatNDJSON},
+		{"result.txt", "", FormatCSV},
+		{"result.csv", "JSON", FormatJSON},
+		{"result.json", "ndjson", FormatNDJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output+"/"+tt.format, func(t *testing.T) {
+			if got := ExportFormat(tt.output, tt.format); got != tt.want {
+				t.Errorf("ExportFormat(%s, %s) = %s, expected %s", tt.output, tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestJSONExporter 测试 JSON 格式输出（包含元数据）
+func TestJSONExporter(t *testing.T) {
+	meta := ExportMeta{Version: "v2.3.0", Mode: "tcping", Port: 443, URL: "https://test.com"}
+	var buf bytes.Buffer
+	if err := (jsonExporter{}).Export(&buf, meta, testExportData()); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+
+	var got struct {
+		Version string     `json:"version"`
+		Mode    string     `json:"mode"`
+		Port    int        `json:"port"`
+		Count   int        `json:"count"`
+		Results []IPRecord `json:"results"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Version != "v2.3.0" || got.Mode != "tcping" || got.Port != 443 {
+		t.Errorf("unexpected meta: %+v", got)
+	}
+	if got.Count != 2 || len(got.Results) != 2 {
+		t.Fatalf("expected 2 results, got count=%d len=%d", got.Count, len(got.Results))
+	}
+	want := IPRecord{IP: "1.1.1.1", Sent: 4, Received: 3, LossRate: 0.25, Delay: 150, DownloadSpeed: 10, Colo: "LAX"}
+	if !reflect.DeepEqual(got.Results[0], want) {
+		t.Errorf("Results[0] = %+v, expected %+v", got.Results[0], want)
+	}
+}
+
+// TestNDJSONExporter 测试 NDJSON 格式输出（每行一个 IP）
+func TestNDJSONExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (ndjsonExporter{}).Export(&buf, ExportMeta{}, testExportData()); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var rec IPRecord
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if rec.IP != "2.2.2.2" || rec.DownloadSpeed != 5 || rec.Colo != "" {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	// 速度单位为 MB/s，字段名不能使用表示 Mbps 的 _mbps 后缀
+	for _, key := range []string{`"download_speed_mb_s":5`, `"single_speed_mb_s":0`, `"upload_speed_mb_s":0`} {
+		if !strings.Contains(lines[1], key) {
+			t.Errorf("line %s missing %s", lines[1], key)
+		}
+	}
+}
+
+// TestExport_ByExtension 测试根据扩展名写入文件
+func TestExport_ByExtension(t *testing.T) {
+	dir := t.TempDir()
+
+	csvFile := filepath.Join(dir, "result.csv")
+	if err := Export(csvFile, "", ExportMeta{}, testExportData()); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	content, err := os.ReadFile(csvFile)
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "IP 地址,") {
+		t.Errorf("unexpected csv header: %q", strings.SplitN(string(content), "\n", 2)[0])
+	}
+
+	jsonFile := filepath.Join(dir, "result.json")
+	if err := Export(jsonFile, "", ExportMeta{Mode: "httping"}, testExportData()); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	content, err = os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatalf("read json: %v", err)
+	}
+	if !json.Valid(content) {
+		t.Errorf("result.json is not valid JSON")
+	}
+}
+
+// TestExport_Error 测试格式不支持或文件无法创建时返回错误而不是退出
+func TestExport_Error(t *testing.T) {
+	dir := t.TempDir()
+	if err := Export(filepath.Join(dir, "result.txt"), "xml", ExportMeta{}, testExportData()); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+	if err := Export(filepath.Join(dir, "missing", "result.csv"), "", ExportMeta{}, testExportData()); err == nil {
+		t.Error("expected error when the file cannot be created")
+	}
+}
@@ -2,12 +2,14 @@ package utils
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/cheggaaa/pb/v3"
 )
 
 type Bar struct {
-	pb *pb.ProgressBar
+	pb       *pb.ProgressBar
+	progress *Progress
 }
 
 func NewBar(count int, MyStrStart, MyStrEnd string) *Bar {
@@ -16,10 +18,89 @@ func NewBar(count int, MyStrStart, MyStrEnd string) *Bar {
 	return &Bar{pb: bar}
 }
 
+// Report 将进度条的计数同步到 progress（progress 为空时不同步）
+func (b *Bar) Report(progress *Progress, stage string) *Bar {
+	b.progress = progress
+	progress.setStage(stage, int(b.pb.Total()))
+	return b
+}
+
 func (b *Bar) Grow(num int, MyStrVal string) {
 	b.pb.Set("MyStr", MyStrVal).Add(num)
+	b.progress.grow(num, MyStrVal)
 }
 
 func (b *Bar) Done() {
 	b.pb.Finish()
 }
+
+// 测速阶段
+const (
+	StagePing     = "ping"     // 延迟测速
+	StageDownload = "download" // 下载测速
+	StageUpload   = "upload"   // 上传测速
+)
+
+// Progress 测速进度，计数与进度条显示的一致（并发安全，nil 时所有操作无效）
+type Progress struct {
+	mu    sync.RWMutex
+	state ProgressState
+}
+
+// ProgressState 某一时刻的测速进度
+type ProgressState struct {
+	Running bool   `json:"running"` // 是否正在测速
+	Stage   string `json:"stage"`   // 当前阶段：ping / download / upload
+	Current int    `json:"current"` // 当前阶段已完成数量
+	Total   int    `json:"total"`   // 当前阶段总数量
+	Value   string `json:"value"`   // 进度条附加信息（延迟测速阶段为可用 IP 数量）
+}
+
+// Start 标记开始测速
+func (p *Progress) Start() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state = ProgressState{Running: true}
+	p.mu.Unlock()
+}
+
+// Finish 标记测速结束（保留最后的计数）
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Running = false
+	p.mu.Unlock()
+}
+
+// State 返回当前测速进度
+func (p *Progress) State() ProgressState {
+	if p == nil {
+		return ProgressState{}
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.state
+}
+
+func (p *Progress) setStage(stage string, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Stage, p.state.Total, p.state.Current, p.state.Value = stage, total, 0, ""
+	p.mu.Unlock()
+}
+
+func (p *Progress) grow(num int, value string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Current += num
+	p.state.Value = value
+	p.mu.Unlock()
+}
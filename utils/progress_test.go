@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+// TestBar_Report 测试进度条计数同步到测速进度
+func TestBar_Report(t *testing.T) {
+	progress := &Progress{}
+	progress.Start()
+
+	bar := NewBar(10, "可用:", "").Report(progress, StagePing)
+	bar.Grow(1, "1")
+	bar.Grow(2, "3")
+	bar.Done()
+
+	got := progress.State()
+	want := ProgressState{Running: true, Stage: StagePing, Current: 3, Total: 10, Value: "3"}
+	if got != want {
+		t.Errorf("State() = %+v, expected %+v", got, want)
+	}
+
+	progress.Finish()
+	if progress.State().Running {
+		t.Error("Running = true after Finish")
+	}
+}
+
+// TestBar_Report_Nil 测试未设置测速进度时进度条正常工作
+func TestBar_Report_Nil(t *testing.T) {
+	var progress *Progress
+	bar := NewBar(1, "", "").Report(progress, StageDownload)
+	bar.Grow(1, "")
+	bar.Done()
+	if got := progress.State(); got != (ProgressState{}) {
+		t.Errorf("State() = %+v, expected zero value", got)
+	}
+}
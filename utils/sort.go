@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortKey 排序键，Desc 为 true 时从大到小排序
+type SortKey struct {
+	Name string
+	Desc bool
+}
+
+// SortOrder 由多个排序键组成的排序方式，前面的键相同时才比较后面的键
+type SortOrder []SortKey
+
+// 默认排序方式
+var (
+	DefaultPingOrder  = SortOrder{{Name: "loss"}, {Name: "delay"}} // 丢包率低的优先，其次延迟低的优先
+	DefaultSpeedOrder = SortOrder{{Name: "speed", Desc: true}}     // 速度高的优先
+)
+
+// 可用的排序键，比较函数返回 a 与 b 的大小关系（-1、0、1）
+var sortKeys = map[string]func(a, b *CloudflareIPData) int{
+	"loss":     func(a, b *CloudflareIPData) int { return compare(a.getLossRate(), b.getLossRate()) },
+	"delay":    func(a, b *CloudflareIPData) int { return compare(a.Delay, b.Delay) },
+	"speed":    func(a, b *CloudflareIPData) int { return compare(a.DownloadSpeed, b.DownloadSpeed) },
+	"upload":   func(a, b *CloudflareIPData) int { return compare(a.UploadSpeed, b.UploadSpeed) },
+	"colo":     func(a, b *CloudflareIPData) int { return compare(a.Colo, b.Colo) },
+	"received": func(a, b *CloudflareIPData) int { return compare(a.Received, b.Received) },
+	"sent":     func(a, b *CloudflareIPData) int { return compare(a.Sended, b.Sended) },
+	"jitter":   statsKey(func(s DelayStats) time.Duration { return s.Jitter }),
+	"stddev":   statsKey(func(s DelayStats) time.Duration { return s.StdDev }),
+	"min":      statsKey(func(s DelayStats) time.Duration { return s.Min }),
+	"max":      statsKey(func(s DelayStats) time.Duration { return s.Max }),
+	"p50":      statsKey(func(s DelayStats) time.Duration { return s.P50 }),
+	"p90":      statsKey(func(s DelayStats) time.Duration { return s.P90 }),
+}
+
+// 排序键别名
+var sortKeyAliases = map[string]string{
+	"recv": "received",
+}
+
+// ParseSortOrder 解析排序表达式，如 "loss,delay,-speed"
+// 键之间以英文逗号分隔，前缀 - 表示从大到小排序，+ 或无前缀表示从小到大排序
+func ParseSortOrder(expr string) (SortOrder, error) {
+	var order SortOrder
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		key := SortKey{Name: strings.TrimLeft(item, "+-"), Desc: strings.HasPrefix(item, "-")}
+		if alias, ok := sortKeyAliases[key.Name]; ok {
+			key.Name = alias
+		}
+		if _, ok := sortKeys[key.Name]; !ok {
+			return nil, fmt.Errorf("未知的排序键[%s]（可用：%s）", key.Name, strings.Join(SortKeyNames(), ", "))
+		}
+		order = append(order, key)
+	}
+	return order, nil
+}
+
+// SortKeyNames 返回所有可用的排序键
+func SortKeyNames() []string {
+	names := make([]string, 0, len(sortKeys))
+	for name := range sortKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String 返回排序表达式
+func (o SortOrder) String() string {
+	items := make([]string, 0, len(o))
+	for _, k := range o {
+		if k.Desc {
+			items = append(items, "-"+k.Name)
+		} else {
+			items = append(items, k.Name)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// Less 判断 a 是否应排在 b 前面
+func (o SortOrder) Less(a, b *CloudflareIPData) bool {
+	for _, k := range o {
+		c := sortKeys[k.Name](a, b)
+		if c == 0 {
+			continue
+		}
+		if k.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// SortBy 按指定排序方式排序（原地），所有键都相同时保持原有顺序
+func (s PingDelaySet) SortBy(order SortOrder) {
+	sort.SliceStable(s, func(i, j int) bool { return order.Less(&s[i], &s[j]) })
+}
+
+// SortBy 按指定排序方式排序（原地），所有键都相同时保持原有顺序
+func (s DownloadSpeedSet) SortBy(order SortOrder) {
+	PingDelaySet(s).SortBy(order)
+}
+
+func statsKey(get func(DelayStats) time.Duration) func(a, b *CloudflareIPData) int {
+	return func(a, b *CloudflareIPData) int { return compare(get(a.Stats()), get(b.Stats())) }
+}
+
+func compare[T float32 | float64 | int | time.Duration | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestParseSortOrder 测试排序表达式解析
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"loss,delay,-speed", "loss,delay,-speed", false},
+		{" -Speed , +delay ", "-speed,delay", false},
+		{"recv,colo", "received,colo", false},
+		{"loss,,jitter", "loss,jitter", false},
+		{"latency", "", true},
+	}
+	for _, tt := range tests {
+		order, err := ParseSortOrder(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSortOrder(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if got := order.String(); got != tt.want {
+			t.Errorf("ParseSortOrder(%q) = %q, expected %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestSortBy 测试多个排序键组合排序
+func TestSortBy(t *testing.T) {
+	newData := func(ip string, recv int, delay time.Duration, speedMB float64) CloudflareIPData {
+		return CloudflareIPData{
+			PingData:      &PingData{IP: &net.IPAddr{IP: net.ParseIP(ip)}, Sended: 4, Received: recv, Delay: delay},
+			DownloadSpeed: speedMB * 1024 * 1024,
+		}
+	}
+	data := DownloadSpeedSet{
+		newData("1.1.1.1", 3, 50*time.Millisecond, 20), // 有丢包
+		newData("2.2.2.2", 4, 80*time.Millisecond, 8),
+		newData("3.3.3.3", 4, 60*time.Millisecond, 8),
+		newData("4.4.4.4", 4, 90*time.Millisecond, 12),
+	}
+
+	// 无丢包的 IP 中速度最快的优先，速度相同时延迟低的优先
+	order, err := ParseSortOrder("loss,-speed,delay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.SortBy(order)
+	want := []string{"4.4.4.4", "3.3.3.3", "2.2.2.2", "1.1.1.1"}
+	for i, ip := range want {
+		if data[i].IP.String() != ip {
+			t.Errorf("SortBy(%s)[%d] = %s, expected %s", order, i, data[i].IP, ip)
+		}
+	}
+
+	// 默认排序与原有的 Less 一致
+	data.SortBy(DefaultSpeedOrder)
+	if data[0].IP.String() != "1.1.1.1" {
+		t.Errorf("SortBy(DefaultSpeedOrder)[0] = %s, expected 1.1.1.1", data[0].IP)
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DelayStats 单个 IP 多次延迟测速的统计
+type DelayStats struct {
+	Min    time.Duration // 最小延迟
+	Max    time.Duration // 最大延迟
+	StdDev time.Duration // 延迟标准差
+	P50    time.Duration // 延迟中位数
+	P90    time.Duration // 90% 分位延迟
+	Jitter time.Duration // 抖动：相邻两次延迟之差的绝对值的平均值
+}
+
+// UpdateStats 根据延迟样本计算并保存延迟统计，需在样本不再变化、结果被共享前调用
+func (pd *PingData) UpdateStats() {
+	stats := newDelayStats(pd.Samples)
+	pd.stats = &stats
+}
+
+// Stats 返回延迟统计，没有延迟样本时返回零值
+// 只读取 UpdateStats 保存的统计，未保存时即时计算，可被多个 goroutine 同时调用
+func (pd *PingData) Stats() DelayStats {
+	if pd.stats != nil {
+		return *pd.stats
+	}
+	return newDelayStats(pd.Samples)
+}
+
+func newDelayStats(samples []time.Duration) DelayStats {
+	if len(samples) == 0 {
+		return DelayStats{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum, jitter time.Duration
+	for i, d := range samples {
+		sum += d
+		if i > 0 {
+			jitter += absDuration(d - samples[i-1])
+		}
+	}
+	mean := float64(sum) / float64(len(samples))
+	var variance float64
+	for _, d := range samples {
+		variance += (float64(d) - mean) * (float64(d) - mean)
+	}
+
+	stats := DelayStats{
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		StdDev: time.Duration(math.Sqrt(variance / float64(len(samples)))),
+		P50:    percentile(sorted, 50),
+		P90:    percentile(sorted, 90),
+	}
+	if len(samples) > 1 {
+		stats.Jitter = jitter / time.Duration(len(samples)-1)
+	}
+	return stats
+}
+
+// percentile 按最近排名法计算已排序样本的 p 分位数
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
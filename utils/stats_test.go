@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func ms(values ...int) []time.Duration {
+	samples := make([]time.Duration, 0, len(values))
+	for _, v := range values {
+		samples = append(samples, time.Duration(v)*time.Millisecond)
+	}
+	return samples
+}
+
+// TestPingData_Stats 测试延迟统计：突发的高延迟会体现在最大值、标准差、P90 及抖动上
+func TestPingData_Stats(t *testing.T) {
+	spiky := (&PingData{Samples: ms(20, 20, 300, 20)}).Stats()
+	steady := (&PingData{Samples: ms(90, 90, 90, 90)}).Stats()
+
+	want := DelayStats{
+		Min:    20 * time.Millisecond,
+		Max:    300 * time.Millisecond,
+		StdDev: 121243556, // sqrt(((70^2)*3 + 210^2) / 4) ms
+		P50:    20 * time.Millisecond,
+		P90:    300 * time.Millisecond,
+		Jitter: 560 * time.Millisecond / 3,
+	}
+	if spiky != want {
+		t.Errorf("Stats() = %+v, want %+v", spiky, want)
+	}
+	if steady.StdDev != 0 || steady.Jitter != 0 || steady.P90 != 90*time.Millisecond {
+		t.Errorf("Stats() of steady samples = %+v", steady)
+	}
+	if got := (&PingData{}).Stats(); got != (DelayStats{}) {
+		t.Errorf("Stats() without samples = %+v, want zero", got)
+	}
+}
+
+// TestPercentile 测试最近排名法分位数
+func TestPercentile(t *testing.T) {
+	sorted := ms(10, 20, 30, 40, 50, 60, 70, 80, 90, 100)
+	if got := percentile(sorted, 50); got != 50*time.Millisecond {
+		t.Errorf("p50 = %v, want 50ms", got)
+	}
+	if got := percentile(sorted, 90); got != 90*time.Millisecond {
+		t.Errorf("p90 = %v, want 90ms", got)
+	}
+	if got := percentile(ms(10), 90); got != 10*time.Millisecond {
+		t.Errorf("p90 of single sample = %v, want 10ms", got)
+	}
+}
+
+// TestPingDelaySet_Jitter 测试按抖动过滤及排序
+func TestPingDelaySet_Jitter(t *testing.T) {
+	data := PingDelaySet{
+		{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Samples: ms(20, 20, 300, 20)}},  // 抖动 186.67ms
+		{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("2.2.2.2")}, Samples: ms(90, 100, 90, 100)}}, // 抖动 10ms
+		{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("3.3.3.3")}, Samples: ms(50, 50, 50, 50)}},   // 抖动 0ms
+	}
+	if got := data.FilterJitter(DefaultMaxJitter); len(got) != 3 {
+		t.Errorf("FilterJitter(default) = %d IPs, want 3", len(got))
+	}
+	filtered := data.FilterJitter(10 * time.Millisecond)
+	if len(filtered) != 2 || filtered[0].IP.String() != "2.2.2.2" {
+		t.Errorf("FilterJitter(10ms) = %v", filtered)
+	}
+
+	data.SortBy(SortOrder{{Name: "jitter"}})
+	want := []string{"3.3.3.3", "2.2.2.2", "1.1.1.1"}
+	for i, ip := range want {
+		if data[i].IP.String() != ip {
+			t.Errorf("SortBy(jitter)[%d] = %s, want %s", i, data[i].IP, ip)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
+)
+
+// Sink 实时接收单个 IP 的测速结果（需并发安全）
+type Sink interface {
+	Send(data CloudflareIPData) error
+}
+
+// NDJSONSink 将每个测速结果以 NDJSON 格式（每行一个 IP）实时写入
+type NDJSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewNDJSONSink 创建写入 w 的 NDJSON 实时输出
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{enc: json.NewEncoder(w)}
+}
+
+// Send 写入一行测速结果
+func (s *NDJSONSink) Send(data CloudflareIPData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(data.Record())
+}
+
+// RedirectStdout 将提示信息、进度条及测速结果等输出改为标准错误，返回原标准输出
+// 用于标准输出只输出 NDJSON 等机器可读数据的场景
+func RedirectStdout() *os.File {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = colorable.NewColorableStderr()
+	return stdout
+}
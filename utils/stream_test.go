@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestNDJSONSink_Concurrent 测试并发写入时每行都是完整的 JSON
+func TestNDJSONSink_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewNDJSONSink(&buf)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = sink.Send(CloudflareIPData{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4}})
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 50 {
+		t.Fatalf("expected 50 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var rec IPRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if rec.IP != "1.1.1.1" {
+			t.Errorf("IP = %s, expected 1.1.1.1", rec.IP)
+		}
+	}
+}